@@ -0,0 +1,219 @@
+package loge
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Backpressure modes
+const (
+	backpressureBlock = iota
+	backpressureDropNewest
+	backpressureDropOldest
+	backpressureDropBelow
+)
+
+// blockRetryInterval is the interval of the expired transactions checks while the caller is blocked
+const blockRetryInterval = 100 * time.Millisecond
+
+// BackpressurePolicy defines what happens to a new record when the buffer limit is reached
+type BackpressurePolicy struct {
+	mode  int
+	level uint32
+}
+
+// Predefined backpressure policies
+var (
+	// Block blocks the caller until the transports deliver the pending records, they expire from the backlog
+	// or the logger shuts down
+	Block = BackpressurePolicy{mode: backpressureBlock}
+	// DropNewest drops the new record, fatal and panic records drop the oldest pending records instead
+	DropNewest = BackpressurePolicy{mode: backpressureDropNewest}
+	// DropOldest drops the oldest pending records to make room for the new record
+	DropOldest = BackpressurePolicy{mode: backpressureDropOldest}
+)
+
+// DropBelow returns a policy dropping the new records less severe than the level,
+// the oldest pending records are dropped to make room for the records of the level or above
+func DropBelow(level uint32) BackpressurePolicy {
+	return BackpressurePolicy{mode: backpressureDropBelow, level: level}
+}
+
+// transactionUsage is the number of records and bytes of a transaction in the backlog
+type transactionUsage struct {
+	records int
+	bytes   int
+}
+
+// severity returns the index of the level in levelSeverity, fatal and panic are the most severe,
+// records without a level are the least severe
+func severity(level uint32) int {
+	if (level & (LogLevelFatal | LogLevelPanic)) != 0 {
+		return len(levelSeverity)
+	}
+
+	for i, l := range levelSeverity {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *buffer) limited() bool {
+	return (b.logger.configuration.BufferRecords > 0) || (b.logger.configuration.BufferBytes > 0)
+}
+
+// full reports if adding the record exceeds the limits, must be called with the backlog lock held
+func (b *buffer) full(size int) bool {
+	c := &b.logger.configuration
+	if (c.BufferRecords > 0) && (b.usage.records+1 > c.BufferRecords) {
+		return true
+	}
+	return (c.BufferBytes > 0) && (b.usage.records > 0) && (b.usage.bytes+size > c.BufferBytes)
+}
+
+// reserve accounts the record in the buffer usage applying the backpressure policy if the limits are reached,
+// returns false if the record should be dropped.  Must be called with the logger timestamp lock held.
+func (b *buffer) reserve(el *BufferElement) bool {
+	size := el.Size()
+	policy := b.logger.configuration.Backpressure
+	blocked := false
+
+	b.backlogLock.Lock()
+	defer b.backlogLock.Unlock()
+
+	for {
+		b.reclaim()
+		if !b.full(size) {
+			break
+		}
+
+		if (el.Level & (LogLevelFatal | LogLevelPanic)) != 0 {
+			// fatal and panic records are always logged, the oldest pending records are dropped to make room for them
+			// unless the policy blocks, the limits are exceeded if nothing is dropped
+			if (policy.mode == backpressureBlock) || !b.dropOldest() {
+				break
+			}
+			continue
+		}
+
+		switch policy.mode {
+		case backpressureDropNewest:
+			atomic.AddUint64(&b.dropped, 1)
+			return false
+		case backpressureDropBelow:
+			if severity(el.Level) < severity(policy.level) {
+				atomic.AddUint64(&b.dropped, 1)
+				return false
+			}
+			fallthrough
+		case backpressureDropOldest:
+			if !b.dropOldest() {
+				// nothing left to drop, the record alone exceeds the limits
+				atomic.AddUint64(&b.dropped, 1)
+				return false
+			}
+			continue
+		}
+
+		// block until the transports free some space, the buffer is flushed so the current transaction is delivered as well
+		if !blocked {
+			blocked = true
+			atomic.AddUint64(&b.blocked, 1)
+		}
+
+		// the writers hold the logger timestamp lock, it is released while waiting so the other writers and
+		// the fatal and panic records admitted over the limits are not held up by the blocked one
+		b.backlogLock.Unlock()
+		b.logger.customTimestampLock.Unlock()
+		select {
+		case b.transactionFlush <- true:
+		default:
+		}
+
+		closing := false
+		select {
+		case <-b.freed:
+		case <-b.closing:
+			closing = true
+		case <-time.After(blockRetryInterval):
+		}
+		b.logger.customTimestampLock.Lock()
+		b.backlogLock.Lock()
+
+		if closing {
+			// the logger is shutting down, the record exceeds the limits and is delivered by the final flush
+			break
+		}
+	}
+
+	b.usage.records++
+	b.usage.bytes += size
+	return true
+}
+
+// release removes the transaction from the buffer usage, must be called with the backlog lock held
+func (b *buffer) release(id uint64) {
+	u, ok := b.transactions[id]
+	if !ok {
+		return
+	}
+
+	delete(b.transactions, id)
+	b.usage.records -= u.records
+	b.usage.bytes -= u.bytes
+
+	select {
+	case b.freed <- struct{}{}:
+	default:
+	}
+}
+
+// reclaim releases the transactions expired from the backlog, must be called with the backlog lock held
+func (b *buffer) reclaim() {
+	for len(b.order) > 0 {
+		id := b.order[0]
+		if u, ok := b.transactions[id]; ok {
+			if b.backlog.Check(id) {
+				return
+			}
+
+			atomic.AddUint64(&b.expired, uint64(u.records))
+			b.release(id)
+		}
+		b.order = b.order[1:]
+	}
+}
+
+// dropOldest drops the oldest transaction from the backlog or the oldest record of the current transaction,
+// must be called with the backlog lock held
+func (b *buffer) dropOldest() bool {
+	for len(b.order) > 0 {
+		id := b.order[0]
+		b.order = b.order[1:]
+
+		if u, ok := b.transactions[id]; ok {
+			b.backlog.Delete(id)
+			atomic.AddUint64(&b.dropped, uint64(u.records))
+			b.release(id)
+			return true
+		}
+	}
+
+	b.currentTransactionLock.Lock()
+	defer b.currentTransactionLock.Unlock()
+
+	if len(b.currentTransaction) == 0 {
+		return false
+	}
+
+	el := b.currentTransaction[0]
+	b.currentTransaction[0] = nil
+	b.currentTransaction = b.currentTransaction[1:]
+	b.currentTransactionSize -= el.Size()
+	b.usage.records--
+	b.usage.bytes -= el.Size()
+	atomic.AddUint64(&b.dropped, 1)
+	return true
+}
@@ -0,0 +1,174 @@
+package loge
+
+import (
+	"testing"
+	"time"
+)
+
+// stalledTransport never delivers nor frees the transactions
+type stalledTransport struct{}
+
+func (stalledTransport) NewTransaction(uint64) {}
+func (stalledTransport) Stop()                 {}
+
+func TestShutdownReleasesBlockedWriters(t *testing.T) {
+	lg := New(
+		BufferLimit(2, 0, Block),
+		TransactionTimeout(10*time.Millisecond),
+		CollapseRepeated(time.Minute),
+		Sampling(SamplingPolicy{Interval: time.Minute, First: 10}),
+		Transports(func(TransactionList) []Transport {
+			return []Transport{stalledTransport{}}
+		}),
+	)
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < 3; i++ {
+			lg.Printf("record %d", i)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for lg.Stats().BufferBlocked == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("writer is not blocked by the buffer limit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		lg.Shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown waits for the blocked writer")
+	}
+
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer is not released at shutdown")
+	}
+}
+
+type recordingHandler struct {
+	messages []string
+}
+
+func (h *recordingHandler) WriteOutTransaction(tr *Transaction) {
+	for _, be := range tr.Items {
+		h.messages = append(h.messages, be.Message)
+	}
+}
+
+func (h *recordingHandler) FlushTransactions() {}
+
+func TestFatalIsNeverDropped(t *testing.T) {
+	for _, policy := range []BackpressurePolicy{Block, DropNewest, DropOldest, DropBelow(LogLevelError)} {
+		h := &recordingHandler{}
+		exited := false
+		lg := New(
+			BufferLimit(1, 0, policy),
+			TransactionTimeout(time.Hour),
+			ExitFunc(func(int) { exited = true }),
+			Transports(func(list TransactionList) []Transport {
+				return []Transport{WrapTransport(list, h)}
+			}),
+		)
+
+		lg.Printf("pending")
+		lg.Fatal("fatal")
+
+		if !exited {
+			t.Fatalf("policy %v: Fatal did not exit", policy)
+		}
+		if (len(h.messages) == 0) || (h.messages[len(h.messages)-1] != "fatal") {
+			t.Fatalf("policy %v: fatal record is not delivered, got %q", policy, h.messages)
+		}
+	}
+}
+
+// asyncTransport delivers the transactions from its own goroutine without implementing Drainer
+type asyncTransport struct {
+	list      TransactionList
+	delivered chan string
+}
+
+func (t *asyncTransport) NewTransaction(id uint64) {
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if tr, ok := t.list.Get(id, true); ok {
+			for _, be := range tr.Items {
+				t.delivered <- be.Message
+			}
+		}
+	}()
+}
+
+func (t *asyncTransport) Stop() {}
+
+func TestPanicWaitsForTransports(t *testing.T) {
+	transport := &asyncTransport{delivered: make(chan string, 10)}
+	lg := New(
+		TransactionTimeout(time.Hour),
+		Transports(func(list TransactionList) []Transport {
+			transport.list = list
+			return []Transport{transport}
+		}),
+	)
+	defer lg.Shutdown()
+
+	func() {
+		defer func() { recover() }()
+		lg.Panic("panic")
+	}()
+
+	select {
+	case message := <-transport.delivered:
+		if message != "panic" {
+			t.Errorf("delivered %q, want %q", message, "panic")
+		}
+	default:
+		t.Error("Panic returned before the transport delivered the record")
+	}
+}
+
+func TestFatalIsNotBlockedByWriters(t *testing.T) {
+	exited := make(chan struct{})
+	lg := New(
+		BufferLimit(1, 0, Block),
+		TransactionTimeout(time.Hour),
+		ExitFunc(func(int) { close(exited) }),
+		Transports(func(TransactionList) []Transport {
+			return []Transport{stalledTransport{}}
+		}),
+	)
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			lg.Printf("record %d", i)
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for lg.Stats().BufferBlocked == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("writer is not blocked by the buffer limit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	go lg.Fatal("fatal")
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fatal waits for the blocked writer")
+	}
+}
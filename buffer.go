@@ -20,9 +20,25 @@ type Transport interface {
 	Stop()
 }
 
+// Drainer is an optional Transport extension blocking until all the transactions
+// received so far are written out
+type Drainer interface {
+	Drain()
+}
+
+const (
+	drainTimeout      = 5 * time.Second       // delivery wait limit for the transports not implementing Drainer
+	drainPollInterval = 10 * time.Millisecond // delivery check interval for the transports not implementing Drainer
+)
+
 type buffer struct {
+	dropped uint64 // records dropped by the backpressure policy, accessed atomically
+	expired uint64 // records expired from the backlog before being delivered, accessed atomically
+	blocked uint64 // writes blocked by the backpressure policy, accessed atomically
+
 	logger            *logger
 	stop              chan struct{}
+	closing           chan struct{} // closed when the logger starts shutting down to release the blocked writers
 	wg                sync.WaitGroup
 	nextTransactionID uint64
 
@@ -32,10 +48,17 @@ type buffer struct {
 
 	transactionFlush chan bool
 	flushSent        bool
+	drainRequest     chan chan struct{}
 
 	backlog     *cache.Line
 	backlogLock sync.Mutex
 
+	// buffer usage accounting, guarded by backlogLock
+	usage        transactionUsage            // records and bytes in the current transaction and the backlog
+	transactions map[uint64]transactionUsage // usage of the transactions in the backlog
+	order        []uint64                    // transactions in the backlog from the oldest
+	freed        chan struct{}               // signaled when a transaction leaves the backlog
+
 	outputs  []Transport
 	refcount int
 }
@@ -52,7 +75,11 @@ func newBuffer(logger *logger) *buffer {
 		nextTransactionID: 1,
 		logger:            logger,
 		transactionFlush:  make(chan bool, 1),
+		drainRequest:      make(chan chan struct{}),
 		stop:              make(chan struct{}),
+		closing:           make(chan struct{}),
+		transactions:      make(map[uint64]transactionUsage),
+		freed:             make(chan struct{}, 1),
 		backlog:           cache.CreateLine(logger.configuration.BacklogExpirationTimeout),
 	}
 }
@@ -61,11 +88,11 @@ func (b *buffer) start(outputs []Transport) {
 	b.outputs = outputs
 	b.refcount = len(outputs)
 
+	b.wg.Add(1)
 	go b.loop()
 }
 
 func (b *buffer) loop() {
-	b.wg.Add(1)
 	defer b.wg.Done()
 
 	tm := time.NewTimer(b.logger.configuration.TransactionTimeout)
@@ -83,11 +110,18 @@ func (b *buffer) loop() {
 		case <-tm.C:
 			b.flush()
 			tm.Reset(b.logger.configuration.TransactionTimeout)
+		case done := <-b.drainRequest:
+			b.flush()
+			close(done)
 		}
 	}
 }
 
 func (b *buffer) write(el *BufferElement) {
+	if b.limited() && !b.reserve(el) {
+		return
+	}
+
 	flush := false
 
 	b.currentTransactionLock.Lock()
@@ -109,6 +143,56 @@ func (b *buffer) write(el *BufferElement) {
 	}
 }
 
+// drain flushes the current transaction and waits until the outputs supporting Drainer write it out, the other
+// outputs are given up to drainTimeout to free it
+func (b *buffer) drain() {
+	done := make(chan struct{})
+	select {
+	case b.drainRequest <- done:
+		<-done
+	case <-b.stop:
+		return
+	}
+
+	// the transaction is created by the loop before the drain request is completed
+	last := b.nextTransactionID - 1
+
+	waiting := false
+	for _, t := range b.outputs {
+		if d, ok := t.(Drainer); ok {
+			d.Drain()
+		} else {
+			waiting = true
+		}
+	}
+
+	if waiting {
+		b.awaitDelivery(last, drainTimeout)
+	}
+}
+
+// awaitDelivery waits up to the timeout until the transaction is freed by all the outputs, used for the transports
+// not implementing Drainer
+func (b *buffer) awaitDelivery(id uint64, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		b.backlogLock.Lock()
+		pending := b.backlog.Check(id)
+		b.backlogLock.Unlock()
+		if !pending {
+			return
+		}
+
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// unblock releases the writers blocked by the backpressure policy, called first at shutdown so the blocked
+// writers are not waiting for the outputs being stopped
+func (b *buffer) unblock() {
+	close(b.closing)
+}
+
 func (b *buffer) shutdown() {
 	close(b.stop)
 	b.wg.Wait()
@@ -127,6 +211,7 @@ func (b *buffer) flush() {
 	}
 
 	tr := b.currentTransaction
+	size := b.currentTransactionSize
 	b.currentTransaction = make([]*BufferElement, 0)
 	b.currentTransactionSize = 0
 	b.currentTransactionLock.Unlock()
@@ -139,6 +224,10 @@ func (b *buffer) flush() {
 
 	b.backlogLock.Lock()
 	b.backlog.Store(b.nextTransactionID, trans)
+	if b.limited() {
+		b.transactions[b.nextTransactionID] = transactionUsage{records: len(tr), bytes: size}
+		b.order = append(b.order, b.nextTransactionID)
+	}
 	b.backlogLock.Unlock()
 
 	for _, t := range b.outputs {
@@ -162,6 +251,7 @@ func (b *buffer) Get(id uint64, autofree bool) (*Transaction, bool) {
 			trans.references--
 			if trans.references == 0 {
 				b.backlog.Delete(id)
+				b.release(id)
 			}
 		}
 
@@ -182,6 +272,7 @@ func (b *buffer) Free(id uint64) {
 		trans.references--
 		if trans.references == 0 {
 			b.backlog.Delete(id)
+			b.release(id)
 		}
 	}
 }
@@ -0,0 +1,105 @@
+package loge
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const maxStackDepth = 64
+
+// capture returns the program counters starting skip frames above the function calling capture.
+// Only the caller is captured unless the stack trace is enabled for the level.
+func (l *logger) capture(skip int, level uint32) []uintptr {
+	stack := (l.configuration.StackTraceLevels & level) != 0
+	if !stack && ((l.configuration.Mode & outputIncludeLine) == 0) {
+		return nil
+	}
+
+	size := 1
+	if stack {
+		size = maxStackDepth
+	}
+
+	pcs := make([]uintptr, size)
+	return pcs[:runtime.Callers(skip+2, pcs)]
+}
+
+// captureAt returns the program counters starting at the frame of the program counter pc reported by the caller
+// (like slog.Record.PC).  Only the frame itself is returned unless the stack trace is enabled for the level, the
+// stack is cut at the frame or starts with it if the frame is not on the current stack.
+func (l *logger) captureAt(pc uintptr, level uint32) []uintptr {
+	if pc == 0 {
+		return nil
+	}
+
+	if (l.configuration.StackTraceLevels & level) == 0 {
+		if (l.configuration.Mode & outputIncludeLine) == 0 {
+			return nil
+		}
+		return []uintptr{pc}
+	}
+
+	pcs := make([]uintptr, maxStackDepth)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+	for i, p := range pcs {
+		if p == pc {
+			return pcs[i:]
+		}
+	}
+
+	return []uintptr{pc}
+}
+
+// setCaller fills the caller fields and the stack trace of the entry from the captured program counters
+func (l *logger) setCaller(be *BufferElement, pcs []uintptr, level uint32) {
+	if len(pcs) == 0 {
+		return
+	}
+
+	frames := runtime.CallersFrames(pcs)
+	frame, _ := frames.Next()
+	be.File = frame.File
+	be.Line = frame.Line
+	be.Function = frame.Function
+
+	if (l.configuration.StackTraceLevels & level) == 0 {
+		return
+	}
+
+	be.Stack = formatStack(pcs)
+}
+
+// formatStack formats the program counters in the "function\n\tfile:line\n" lines
+func formatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		sb.WriteByte('\n')
+
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// caller returns the short file name and line in the log.Lshortfile format "file.go:12: "
+func (be *BufferElement) caller() string {
+	if be.File == "" {
+		return ""
+	}
+
+	return filepath.Base(be.File) + ":" + strconv.Itoa(be.Line) + ": "
+}
@@ -0,0 +1,231 @@
+package loge
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Compressor defines a pluggable compression algorithm applied to the rotated log files
+type Compressor interface {
+	// Extension returns the suffix appended to the compressed file name (for example ".gz")
+	Extension() string
+	// NewWriter wraps the destination writer, the returned writer is closed once the file is compressed
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+// Gzip returns a gzip compressor with the default compression level
+func Gzip() Compressor {
+	return &gzipCompressor{level: gzip.DefaultCompression}
+}
+
+func (c *gzipCompressor) Extension() string {
+	return ".gz"
+}
+
+func (c *gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+const (
+	compressTempSuffix = ".tmp"
+	rolloverSuffix     = ".rollover-" // suffix of the rolled over files waiting to be shifted into the backups
+)
+
+// compressJob is a file waiting for the background compression, the staged files are shifted into
+// the numbered backups of base first
+type compressJob struct {
+	name string
+	base string
+}
+
+// compress schedules the background compression of a closed log file
+func (ft *fileOutputTransport) compress(name string) {
+	if ft.compressor == nil {
+		return
+	}
+
+	ft.compressLock.Lock()
+	defer ft.compressLock.Unlock()
+
+	if ft.compressing[name] {
+		return
+	}
+	ft.compressing[name] = true
+	ft.enqueue(compressJob{name: name})
+}
+
+// stage renames the rolled over file to a unique name and schedules shifting it into the numbered backups
+// followed by the compression, so the rollover never waits for the previous backup to be compressed
+func (ft *fileOutputTransport) stage(name string) error {
+	staged := name + rolloverSuffix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := os.Rename(name, staged); err != nil {
+		return err
+	}
+
+	ft.compressLock.Lock()
+	ft.enqueue(compressJob{name: staged, base: name})
+	ft.compressLock.Unlock()
+	return nil
+}
+
+// enqueue adds the job to the compression queue starting the compression goroutine if it is not running,
+// must be called with the compress lock held
+func (ft *fileOutputTransport) enqueue(job compressJob) {
+	ft.compressQueue = append(ft.compressQueue, job)
+	if ft.compressRunning {
+		return
+	}
+
+	ft.compressRunning = true
+	ft.compressWG.Add(1)
+	go ft.compressLoop()
+}
+
+// compressLoop compresses the queued files one by one.  While the compression is enabled the numbered backups
+// are renamed only by this goroutine, so a file is never renamed while being compressed.
+func (ft *fileOutputTransport) compressLoop() {
+	defer ft.compressWG.Done()
+
+	for {
+		ft.compressLock.Lock()
+		if len(ft.compressQueue) == 0 {
+			ft.compressRunning = false
+			ft.compressLock.Unlock()
+			return
+		}
+
+		job := ft.compressQueue[0]
+		ft.compressQueue = ft.compressQueue[1:]
+
+		name := job.name
+		if job.base != "" {
+			shiftBackups(job.base, job.name, ft.compressor.Extension())
+			name = backupName(job.base, 1)
+			ft.compressing[name] = true
+		}
+		ft.compressLock.Unlock()
+
+		if err := ft.compressFile(name); err != nil {
+			// the file is left uncompressed and retried next time the logger starts
+			ft.reportError(err)
+		}
+
+		ft.compressLock.Lock()
+		delete(ft.compressing, name)
+		ft.compressLock.Unlock()
+
+		// the retention policy is enforced again for the compressed file in the transport goroutine
+		select {
+		case ft.compressed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// compressFile compresses the file into a temporary file first and replaces the source only when the
+// compressed copy is complete so an interrupted compression never loses the data
+func (ft *fileOutputTransport) compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	target := name + ft.compressor.Extension()
+	temp := target + compressTempSuffix
+
+	dst, err := ft.openFile(temp, os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+
+	w, err := ft.compressor.NewWriter(dst)
+	if err == nil {
+		_, err = io.Copy(w, src)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err == nil {
+		err = dst.Sync()
+	}
+
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		// keep the modification time of the source for the retention policy ordering
+		if info, statErr := src.Stat(); statErr == nil {
+			os.Chtimes(temp, info.ModTime(), info.ModTime())
+		}
+		err = os.Rename(temp, target)
+	}
+
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(name)
+}
+
+// recoverCompression cleans up compressions interrupted by the process exit and restarts
+// the compression of rotated files left uncompressed
+func (ft *fileOutputTransport) recoverCompression() {
+	if ft.compressor == nil {
+		return
+	}
+
+	entries, err := ioutil.ReadDir(ft.path)
+	if err != nil {
+		return
+	}
+
+	ext := ft.compressor.Extension()
+	current := filepath.Base(ft.currentFilename)
+	var staged []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (name == current) {
+			continue
+		}
+
+		if !ft.rotation && strings.HasPrefix(name, ft.filename+rolloverSuffix) {
+			staged = append(staged, name)
+			continue
+		}
+
+		if strings.HasSuffix(name, ext+compressTempSuffix) {
+			if ft.isBackup(strings.TrimSuffix(name, compressTempSuffix)) {
+				os.Remove(filepath.Join(ft.path, name))
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(name, ext) && ft.isBackup(name) {
+			ft.compress(filepath.Join(ft.path, name))
+		}
+	}
+
+	// files rolled over before the process exit are shifted into the backups in the order they were staged
+	sort.Strings(staged)
+	ft.compressLock.Lock()
+	for _, name := range staged {
+		ft.enqueue(compressJob{name: filepath.Join(ft.path, name), base: ft.currentFilename})
+	}
+	ft.compressLock.Unlock()
+}
@@ -0,0 +1,169 @@
+package loge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ContextExtractor returns the key/values extracted from the context (for example trace and span IDs)
+type ContextExtractor func(ctx context.Context) map[string]interface{}
+
+type contextKey struct{}
+
+// contextData is a logger and a set of key/values stored in the context, never modified once stored
+type contextData struct {
+	logger *Logger
+	fields map[string]interface{}
+}
+
+func fromContext(ctx context.Context) contextData {
+	if ctx == nil {
+		return contextData{}
+	}
+
+	cd, _ := ctx.Value(contextKey{}).(contextData)
+	return cd
+}
+
+// NewContext returns a copy of the context carrying the key/values in addition to the ones already stored.
+// Stored key/values are added to the records created with WithContext and *Context functions.
+func NewContext(ctx context.Context, fields map[string]interface{}) context.Context {
+	cd := fromContext(ctx)
+
+	merged := make(map[string]interface{}, len(cd.fields)+len(fields))
+	for k, v := range cd.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, contextKey{}, contextData{logger: cd.logger, fields: merged})
+}
+
+// ContextWithLogger returns a copy of the context carrying the logger used by the package level WithContext
+// and *Context functions instead of the default logger
+func ContextWithLogger(ctx context.Context, lg *Logger) context.Context {
+	cd := fromContext(ctx)
+	return context.WithValue(ctx, contextKey{}, contextData{logger: lg, fields: cd.fields})
+}
+
+// contextLogger returns the logger stored in the context or the default logger
+func contextLogger(ctx context.Context) *Logger {
+	if lg := fromContext(ctx).logger; lg != nil {
+		return lg
+	}
+	return std
+}
+
+// WithContext returns a child logger of the logger stored in the context (or the default logger) with the
+// context key/values and the values of the configured extractors bound
+func WithContext(ctx context.Context) *Logger {
+	return contextLogger(ctx).WithContext(ctx)
+}
+
+// WithContext returns a child logger with the context key/values and the values of the configured extractors bound
+func (lg *Logger) WithContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return lg
+	}
+
+	cd := fromContext(ctx)
+	if (len(cd.fields) == 0) && (len(lg.core.configuration.ContextExtractors) == 0) {
+		return lg
+	}
+
+	fields := appendSortedFields(nil, cd.fields)
+	for _, extract := range lg.core.configuration.ContextExtractors {
+		fields = appendSortedFields(fields, extract(ctx))
+	}
+
+	return lg.WithFields(fields...)
+}
+
+// appendSortedFields appends the key/values as fields in the key order so the output is deterministic
+func appendSortedFields(fields []Field, data map[string]interface{}) []Field {
+	keys := make([]string, 0, len(data))
+	for k, v := range data {
+		if k != "" && v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fields = append(fields, Any(k, data[k]))
+	}
+	return fields
+}
+
+// InfoContext creates a new "info" log entry with the context key/values
+func InfoContext(ctx context.Context, format string, v ...interface{}) {
+	if lg := contextLogger(ctx); lg.enabled(LogLevelInfo) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelInfo, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// DebugContext creates a new "debug" log entry with the context key/values
+func DebugContext(ctx context.Context, format string, v ...interface{}) {
+	if lg := contextLogger(ctx); lg.enabled(LogLevelDebug) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelDebug, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// TraceContext creates a new "trace" log entry with the context key/values
+func TraceContext(ctx context.Context, format string, v ...interface{}) {
+	if lg := contextLogger(ctx); lg.enabled(LogLevelTrace) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelTrace, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// WarnContext creates a new "warning" log entry with the context key/values
+func WarnContext(ctx context.Context, format string, v ...interface{}) {
+	if lg := contextLogger(ctx); lg.enabled(LogLevelWarning) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelWarning, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// ErrorContext creates a new "error" log entry with the context key/values
+func ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	if lg := contextLogger(ctx); lg.enabled(LogLevelError) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelError, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// InfoContext creates a new "info" log entry with the context key/values
+func (lg *Logger) InfoContext(ctx context.Context, format string, v ...interface{}) {
+	if lg.enabled(LogLevelInfo) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelInfo, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// DebugContext creates a new "debug" log entry with the context key/values
+func (lg *Logger) DebugContext(ctx context.Context, format string, v ...interface{}) {
+	if lg.enabled(LogLevelDebug) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelDebug, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// TraceContext creates a new "trace" log entry with the context key/values
+func (lg *Logger) TraceContext(ctx context.Context, format string, v ...interface{}) {
+	if lg.enabled(LogLevelTrace) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelTrace, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// WarnContext creates a new "warning" log entry with the context key/values
+func (lg *Logger) WarnContext(ctx context.Context, format string, v ...interface{}) {
+	if lg.enabled(LogLevelWarning) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelWarning, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// ErrorContext creates a new "error" log entry with the context key/values
+func (lg *Logger) ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	if lg.enabled(LogLevelError) {
+		lg.WithContext(ctx).writeLevel(1, LogLevelError, format, fmt.Sprintf(format, v...))
+	}
+}
@@ -1,8 +1,10 @@
 package loge
 
 import (
-	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,24 +15,23 @@ type BufferElement struct {
 	Message     string                     `json:"msg"`
 	Level       uint32                     `json:"-"`
 	Levelstring string                     `json:"level,omitempty"`
+	Logger      string                     `json:"logger,omitempty"`
+	File        string                     `json:"file,omitempty"`
+	Line        int                        `json:"line,omitempty"`
+	Function    string                     `json:"func,omitempty"`
+	Stack       string                     `json:"stack,omitempty"`
 	Data        map[string]interface{}     `json:"data,omitempty"`
+	Fields      []Field                    `json:"-"` // ordered fields, serialized into "data" before the Data values
 
-	l *logger
+	lg       *Logger
+	template string                 // message format used as the sampling key
+	values   map[string]interface{} // Data values other than the fields once the fields are merged into Data
+	merged   bool
 }
 
-func inPlaceBufferElement(l *logger) *BufferElement {
-	be := &BufferElement{
-		l:    l,
-		Data: make(map[string]interface{}),
-	}
-
-	if len(l.configuration.defaultData) > 0 {
-		for k, v := range l.configuration.defaultData {
-			be.Data[k] = v
-		}
-	}
-
-	return be
+// element creates the log entry with the logger name and fields
+func (lg *Logger) element() *BufferElement {
+	return &BufferElement{lg: lg, Logger: lg.name, Fields: lg.boundFields()}
 }
 
 func (be *BufferElement) fill(t time.Time, buf []byte, msg []byte, level uint32) {
@@ -45,21 +46,87 @@ func (be *BufferElement) fill(t time.Time, buf []byte, msg []byte, level uint32)
 	}
 }
 
-func (be *BufferElement) serializeData() string {
-	var serializedData string
-	for key, arg := range be.Data {
-		if len(serializedData) > 0 {
-			serializedData += ", "
+// appendData appends the optional data in the "<key: value, ...> " format, see eachData
+func (be *BufferElement) appendData(buf []byte, sorted bool) []byte {
+	if !be.hasData() {
+		return buf
+	}
+
+	buf = append(buf, '<')
+	first := true
+	be.eachData(sorted, func(key string, f *Field, value interface{}) bool {
+		if !first {
+			buf = append(buf, ", "...)
 		}
+		first = false
+		buf = append(buf, key...)
+		buf = append(buf, ": "...)
+		if f != nil {
+			buf = f.appendText(buf, sorted)
+		} else {
+			buf = append(buf, fmt.Sprint(value)...)
+		}
+		return true
+	})
+
+	return append(buf, "> "...)
+}
 
-		serializedData += fmt.Sprintf("%s: %v", key, arg)
+// dataValues returns the Data values which are not the fields
+func (be *BufferElement) dataValues() map[string]interface{} {
+	if be.merged {
+		return be.values
 	}
+	return be.Data
+}
 
-	if serializedData != "" {
-		serializedData = "<" + serializedData + "> "
+func (be *BufferElement) hasData() bool {
+	return (len(be.Fields) > 0) || (len(be.dataValues()) > 0)
+}
+
+// eachData calls f for the fields in the insertion order (default data first, then With order) followed
+// by the Data values in the key order, or for all of them in the key order if sorted, until f returns false
+func (be *BufferElement) eachData(sorted bool, f func(key string, field *Field, value interface{}) bool) {
+	data := be.dataValues()
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	return serializedData
+	if !sorted {
+		for i := range be.Fields {
+			if !f(be.Fields[i].Key, &be.Fields[i], nil) {
+				return
+			}
+		}
+		for _, k := range keys {
+			if !f(k, nil, data[k]) {
+				return
+			}
+		}
+		return
+	}
+
+	fields := make([]int, len(be.Fields))
+	for i := range fields {
+		fields[i] = i
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return be.Fields[fields[i]].Key < be.Fields[fields[j]].Key })
+
+	for i, j := 0, 0; (i < len(fields)) || (j < len(keys)); {
+		if (j == len(keys)) || ((i < len(fields)) && (be.Fields[fields[i]].Key <= keys[j])) {
+			if !f(be.Fields[fields[i]].Key, &be.Fields[fields[i]], nil) {
+				return
+			}
+			i++
+			continue
+		}
+		if !f(keys[j], nil, data[keys[j]]) {
+			return
+		}
+		j++
+	}
 }
 
 // NewBufferElement creates a new log entry
@@ -71,69 +138,95 @@ func NewBufferElement(t time.Time, buf []byte, msg []byte, level uint32) *Buffer
 
 // Marshal marshals the record into json format
 func (be *BufferElement) Marshal() ([]byte, error) {
-	return json.Marshal(be)
+	sorted := (be.lg != nil) && ((be.lg.core.configuration.Mode & outputSortedFields) != 0)
+	return be.appendJSON(make([]byte, 0, 256), sorted)
 }
 
 // Size returns the record size in bytes
 func (be *BufferElement) Size() int {
 	// we do not count optional data fields in overall size
 	// for simplicity and speed
-	return dateTimeStringLength + len(be.Message)
+	return dateTimeStringLength + len(be.Logger) + len(be.Message)
 }
 
 // With extends the log entry with optional parameters
 func (be *BufferElement) With(key string, value interface{}) *BufferElement {
 	if key != "" && value != nil {
-		be.Data[key] = value
+		be.Fields = withField(be.Fields, Any(key, value))
 	}
 	return be
 }
 
 // Printf creates creates a new log entry
 func (be *BufferElement) Printf(format string, v ...interface{}) {
-	if be.l != nil {
-		be.l.submit(be, fmt.Sprintf(format, v...), 0)
+	if be.lg != nil {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), 0)
 	}
 }
 
 // Println creates creates a new log entry
 func (be *BufferElement) Println(v ...interface{}) {
-	if be.l != nil {
-		be.l.submit(be, fmt.Sprintln(v...), 0)
+	if be.lg != nil {
+		be.lg.submit(1, be, "", fmt.Sprintln(v...), 0)
 	}
 }
 
 // Info creates creates a new "info" log entry
 func (be *BufferElement) Info(format string, v ...interface{}) {
-	if (be.l != nil) && ((be.l.configuration.LogLevels & LogLevelInfo) != 0) {
-		be.l.submit(be, fmt.Sprintf(format, v...), LogLevelInfo)
+	if (be.lg != nil) && be.lg.enabled(LogLevelInfo) {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), LogLevelInfo)
 	}
 }
 
 // Debug creates creates a new "debug" log entry
 func (be *BufferElement) Debug(format string, v ...interface{}) {
-	if (be.l != nil) && ((be.l.configuration.LogLevels & LogLevelDebug) != 0) {
-		be.l.submit(be, fmt.Sprintf(format, v...), LogLevelDebug)
+	if (be.lg != nil) && be.lg.enabled(LogLevelDebug) {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), LogLevelDebug)
 	}
 }
 
 // Trace creates creates a new "trace" log entry
 func (be *BufferElement) Trace(format string, v ...interface{}) {
-	if (be.l != nil) && ((be.l.configuration.LogLevels & LogLevelTrace) != 0) {
-		be.l.submit(be, fmt.Sprintf(format, v...), LogLevelTrace)
+	if (be.lg != nil) && be.lg.enabled(LogLevelTrace) {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), LogLevelTrace)
 	}
 }
 
 // Warn creates creates a new "warning" log entry
 func (be *BufferElement) Warn(format string, v ...interface{}) {
-	if (be.l != nil) && ((be.l.configuration.LogLevels & LogLevelWarning) != 0) {
-		be.l.submit(be, fmt.Sprintf(format, v...), LogLevelWarning)
+	if (be.lg != nil) && be.lg.enabled(LogLevelWarning) {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), LogLevelWarning)
 	}
 }
 
 // Error creates creates a new "error" log entry
 func (be *BufferElement) Error(format string, v ...interface{}) {
-	if (be.l != nil) && ((be.l.configuration.LogLevels & LogLevelError) != 0) {
-		be.l.submit(be, fmt.Sprintf(format, v...), LogLevelError)
+	if (be.lg != nil) && be.lg.enabled(LogLevelError) {
+		be.lg.submit(1, be, format, fmt.Sprintf(format, v...), LogLevelError)
+	}
+}
+
+// Fatal creates a new "fatal" log entry, flushes all the outputs and terminates the process with os.Exit(1)
+func (be *BufferElement) Fatal(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	if be.lg != nil {
+		be.lg.submit(1, be, format, message, LogLevelFatal)
+		be.lg.core.fatal()
+		return
+	}
+
+	// the entry is not bound to a logger, the message is reported to os.Stderr like an unrecovered panic would be
+	os.Stderr.Write([]byte(strings.TrimSuffix(message, "\n") + "\n"))
+	os.Exit(1)
+}
+
+// Panic creates a new "panic" log entry, flushes all the outputs and panics with the message.  Transports
+// not implementing Drainer are given up to 5 seconds to deliver the record
+func (be *BufferElement) Panic(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	if be.lg != nil {
+		be.lg.submit(1, be, format, message, LogLevelPanic)
+		be.lg.core.drain()
 	}
+	panic(message)
 }
@@ -0,0 +1,160 @@
+package loge
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrorKey is the Data key used by WithError
+const ErrorKey = "error"
+
+// errorValue is the Data value recorded by WithError, it is serialized to JSON as
+// {"msg": ..., "type": ..., "chain": [...], "stack": ...} and prints the error message otherwise
+type errorValue struct {
+	err error
+}
+
+type errorJSON struct {
+	Message string   `json:"msg"`
+	Type    string   `json:"type"`
+	Chain   []string `json:"chain,omitempty"`
+	Stack   string   `json:"stack,omitempty"`
+}
+
+// Error returns the message of the recorded error
+func (ev errorValue) Error() string {
+	return ev.err.Error()
+}
+
+// Unwrap returns the recorded error
+func (ev errorValue) Unwrap() error {
+	return ev.err
+}
+
+// MarshalJSON serializes the error message, the concrete type, the wrapped errors and the stack trace
+func (ev errorValue) MarshalJSON() ([]byte, error) {
+	ej := errorJSON{
+		Message: ev.err.Error(),
+		Type:    fmt.Sprintf("%T", ev.err),
+	}
+
+	var stack string
+	unwrapErrors(ev.err, func(err error) {
+		ej.Chain = append(ej.Chain, err.Error())
+		if s := errorStack(err); s != "" {
+			stack = s // the innermost stack trace is the closest to the origin of the error
+		}
+	})
+
+	ej.Stack = errorStack(ev.err)
+	if stack != "" {
+		ej.Stack = stack
+	}
+
+	return json.Marshal(ej)
+}
+
+// unwrapErrors walks the errors wrapped by err depth first following both
+// Unwrap() error and Unwrap() []error (errors.Join)
+func unwrapErrors(err error, f func(error)) {
+	var wrapped []error
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := e.Unwrap(); w != nil {
+			wrapped = []error{w}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = e.Unwrap()
+	}
+
+	for _, w := range wrapped {
+		if w == nil {
+			continue
+		}
+		f(w)
+		unwrapErrors(w, f)
+	}
+}
+
+// errorStack returns the stack trace exposed by the error with either StackTrace() or Stack() method,
+// e.g. github.com/pkg/errors or github.com/go-errors/errors, empty string if there is none
+func errorStack(err error) string {
+	v := reflect.ValueOf(err)
+	for _, name := range []string{"StackTrace", "Stack"} {
+		m := v.MethodByName(name)
+		if !m.IsValid() || (m.Type().NumIn() != 0) || (m.Type().NumOut() != 1) {
+			continue
+		}
+
+		switch s := m.Call(nil)[0].Interface().(type) {
+		case string:
+			return s
+		case []byte:
+			return string(s)
+		case []uintptr:
+			return formatStack(s)
+		default:
+			return strings.TrimPrefix(fmt.Sprintf("%+v", s), "\n")
+		}
+	}
+
+	return ""
+}
+
+// hasErrors reports if the data contains error values serialized by encoding/json as empty objects
+func hasErrors(data map[string]interface{}) bool {
+	for _, v := range data {
+		switch t := v.(type) {
+		case json.Marshaler:
+		case error:
+			return true
+		case map[string]interface{}:
+			if hasErrors(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceErrors returns a copy of the data with error values replaced by their messages
+func replaceErrors(data map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		switch t := v.(type) {
+		case json.Marshaler:
+			res[k] = v
+		case error:
+			res[k] = t.Error()
+		case map[string]interface{}:
+			res[k] = replaceErrors(t)
+		default:
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// WithError creates a new log entry with the error recorded under the "error" key
+func WithError(err error) *BufferElement {
+	return With(ErrorKey, newErrorValue(err))
+}
+
+// WithError creates a child logger with the error recorded under the "error" key, see With
+func (lg *Logger) WithError(err error) *Logger {
+	return lg.With(ErrorKey, newErrorValue(err))
+}
+
+// WithError extends the log entry with the error recorded under the "error" key
+func (be *BufferElement) WithError(err error) *BufferElement {
+	return be.With(ErrorKey, newErrorValue(err))
+}
+
+func newErrorValue(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	return errorValue{err: err}
+}
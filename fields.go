@@ -0,0 +1,314 @@
+package loge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FieldType is the type of the value stored in a Field
+type FieldType uint8
+
+// Field value types
+const (
+	FieldAny FieldType = iota
+	FieldString
+	FieldInt
+	FieldDuration
+	FieldTime
+	FieldBool
+	FieldInt64
+)
+
+// Field is a typed key/value pair attached to the log entry.  Typed fields are stored in the order they were added
+// and encoded without going through reflection or intermediate maps.
+type Field struct {
+	Key  string
+	Type FieldType
+
+	integer int64
+	str     string
+	iface   interface{}
+}
+
+// String creates a string field
+func String(key string, value string) Field {
+	return Field{Key: key, Type: FieldString, str: value}
+}
+
+// Int creates an integer field
+func Int(key string, value int) Field {
+	return Field{Key: key, Type: FieldInt, integer: int64(value)}
+}
+
+// Int64 creates an int64 field
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Type: FieldInt64, integer: value}
+}
+
+// Duration creates a time.Duration field
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Type: FieldDuration, integer: int64(value)}
+}
+
+// Time creates a time.Time field, the location is preserved but the monotonic clock reading is not
+func Time(key string, value time.Time) Field {
+	if y := value.Year(); (y < 1678) || (y > 2261) {
+		// out of the UnixNano range
+		return Field{Key: key, Type: FieldAny, iface: value}
+	}
+	return Field{Key: key, Type: FieldTime, integer: value.UnixNano(), iface: value.Location()}
+}
+
+// Bool creates a boolean field
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, Type: FieldBool}
+	if value {
+		f.integer = 1
+	}
+	return f
+}
+
+// Any creates a field of an arbitrary value, values of the supported types are stored as typed fields
+func Any(key string, value interface{}) Field {
+	switch v := value.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case bool:
+		return Bool(key, v)
+	}
+	return Field{Key: key, Type: FieldAny, iface: value}
+}
+
+// Value returns the field value of the same type it was created with
+func (f Field) Value() interface{} {
+	switch f.Type {
+	case FieldString:
+		return f.str
+	case FieldInt:
+		return int(f.integer)
+	case FieldInt64:
+		return f.integer
+	case FieldDuration:
+		return time.Duration(f.integer)
+	case FieldTime:
+		return f.time()
+	case FieldBool:
+		return f.integer != 0
+	}
+	if g, ok := f.iface.(fieldGroup); ok {
+		return g.data()
+	}
+	return f.iface
+}
+
+func (f Field) time() time.Time {
+	t := time.Unix(0, f.integer)
+	if loc, ok := f.iface.(*time.Location); ok {
+		return t.In(loc)
+	}
+	return t
+}
+
+// appendText appends the value formatted in the same way as fmt %v, groups are formatted as "{key: value, ...}"
+func (f Field) appendText(buf []byte, sorted bool) []byte {
+	switch f.Type {
+	case FieldString:
+		return append(buf, f.str...)
+	case FieldInt, FieldInt64:
+		return strconv.AppendInt(buf, f.integer, 10)
+	case FieldDuration:
+		return append(buf, time.Duration(f.integer).String()...)
+	case FieldTime:
+		return f.time().AppendFormat(buf, "2006-01-02 15:04:05.999999999 -0700 MST")
+	case FieldBool:
+		return strconv.AppendBool(buf, f.integer != 0)
+	}
+	if g, ok := f.iface.(fieldGroup); ok {
+		return g.appendText(buf, sorted)
+	}
+	return append(buf, fmt.Sprint(f.iface)...)
+}
+
+// appendJSON appends the value encoded in the same way as encoding/json, groups are encoded as nested objects
+func (f Field) appendJSON(buf []byte, sorted bool) ([]byte, error) {
+	switch f.Type {
+	case FieldString:
+		return appendJSONString(buf, f.str), nil
+	case FieldInt, FieldInt64, FieldDuration:
+		return strconv.AppendInt(buf, f.integer, 10), nil
+	case FieldTime:
+		buf = append(buf, '"')
+		buf = f.time().AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"'), nil
+	case FieldBool:
+		return strconv.AppendBool(buf, f.integer != 0), nil
+	}
+	if g, ok := f.iface.(fieldGroup); ok {
+		return g.appendJSON(buf, sorted)
+	}
+	return appendJSONValue(buf, f.iface)
+}
+
+// fieldGroup is the value of a group field holding the nested fields in the order they were added
+type fieldGroup []Field
+
+// withGroupField adds the field into the nested groups creating the missing ones, the groups on the path are copied
+// as they might be shared with the logger or other records
+func withGroupField(fields []Field, groups []string, f Field) []Field {
+	fields = fields[:len(fields):len(fields)]
+	if len(groups) == 0 {
+		return withField(fields, f)
+	}
+
+	var nested fieldGroup
+	for i := range fields {
+		if fields[i].Key == groups[0] {
+			nested, _ = fields[i].iface.(fieldGroup)
+			break
+		}
+	}
+
+	nested = withGroupField(nested, groups[1:], f)
+	return withField(fields, Field{Key: groups[0], Type: FieldAny, iface: nested})
+}
+
+// data returns the group as a map with the nested groups converted to maps
+func (g fieldGroup) data() map[string]interface{} {
+	data := make(map[string]interface{}, len(g))
+	for _, f := range g {
+		data[f.Key] = f.Value()
+	}
+	return data
+}
+
+// each calls f for the fields in the insertion order or in the key order if sorted until f returns false
+func (g fieldGroup) each(sorted bool, f func(field *Field) bool) {
+	order := make([]int, len(g))
+	for i := range order {
+		order[i] = i
+	}
+	if sorted {
+		sort.SliceStable(order, func(i, j int) bool { return g[order[i]].Key < g[order[j]].Key })
+	}
+
+	for _, i := range order {
+		if !f(&g[i]) {
+			return
+		}
+	}
+}
+
+func (g fieldGroup) appendText(buf []byte, sorted bool) []byte {
+	buf = append(buf, '{')
+	first := true
+	g.each(sorted, func(f *Field) bool {
+		if !first {
+			buf = append(buf, ", "...)
+		}
+		first = false
+		buf = append(buf, f.Key...)
+		buf = append(buf, ": "...)
+		buf = f.appendText(buf, sorted)
+		return true
+	})
+	return append(buf, '}')
+}
+
+func (g fieldGroup) appendJSON(buf []byte, sorted bool) ([]byte, error) {
+	var err error
+	buf = append(buf, '{')
+	first := true
+	g.each(sorted, func(f *Field) bool {
+		if !first {
+			buf = append(buf, ',')
+		}
+		first = false
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf, err = f.appendJSON(buf, sorted)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '}'), nil
+}
+
+// WithFields creates a new log entry with the typed fields
+func WithFields(fields ...Field) *BufferElement {
+	return std.element().WithFields(fields...)
+}
+
+// WithFields creates a child logger with the typed fields bound to every record it creates, see With
+func (lg *Logger) WithFields(fields ...Field) *Logger {
+	bound := make([]Field, 0, len(lg.boundFields())+len(fields))
+	bound = append(bound, lg.boundFields()...)
+	for _, f := range fields {
+		bound = withField(bound, f)
+	}
+
+	return &Logger{core: lg.core, name: lg.name, fields: bound}
+}
+
+// WithFields extends the log entry with the typed fields
+func (be *BufferElement) WithFields(fields ...Field) *BufferElement {
+	for _, f := range fields {
+		be.Fields = withField(be.Fields, f)
+	}
+	return be
+}
+
+// boundFields returns the fields bound to the logger or the default data for the loggers without bound fields
+func (lg *Logger) boundFields() []Field {
+	fields := lg.fields
+	if fields == nil {
+		fields = lg.core.configuration.defaultFields
+	}
+
+	// limit the capacity so appending fields to the record never changes the shared slice
+	return fields[:len(fields):len(fields)]
+}
+
+// withField appends the field or replaces the value of the field with the same key keeping its position,
+// the slice is copied before the replacement as it might be shared
+func withField(fields []Field, f Field) []Field {
+	for i := range fields {
+		if fields[i].Key == f.Key {
+			replaced := make([]Field, len(fields))
+			copy(replaced, fields)
+			replaced[i] = f
+			return replaced
+		}
+	}
+	return append(fields, f)
+}
+
+// mergeFields copies the typed fields into the Data map for the transports accessing Data directly,
+// the encoders keep using the ordered fields
+func (be *BufferElement) mergeFields() {
+	if (len(be.Fields) == 0) || be.merged {
+		return
+	}
+
+	data := make(map[string]interface{}, len(be.Data)+len(be.Fields))
+	for k, v := range be.Data {
+		data[k] = v
+	}
+	for _, f := range be.Fields {
+		data[f.Key] = f.Value()
+	}
+
+	be.values = be.Data
+	be.Data = data
+	be.merged = true
+}
@@ -0,0 +1,109 @@
+package loge
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// the map path stores the optional data in the Data map and serializes the record with encoding/json,
+// the typed path stores it in the ordered fields and uses the dedicated encoders
+
+func benchmarkElement() *BufferElement {
+	return NewBufferElement(time.Now(), nil, []byte("request completed"), LogLevelInfo)
+}
+
+func BenchmarkEncodeJSONMap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		be := benchmarkElement()
+		be.Data = map[string]interface{}{"user": "bob", "uid": 42, "elapsed": 3 * time.Millisecond, "cached": true}
+		if _, err := json.Marshal(be); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeJSONFields(b *testing.B) {
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		be := benchmarkElement().WithFields(String("user", "bob"), Int("uid", 42), Duration("elapsed", 3*time.Millisecond), Bool("cached", true))
+		if _, err := be.appendJSON(buf[:0], false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeTextMap(b *testing.B) {
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		be := benchmarkElement()
+		be.Data = map[string]interface{}{"user": "bob", "uid": 42, "elapsed": 3 * time.Millisecond, "cached": true}
+		be.appendData(buf[:0], false)
+	}
+}
+
+func BenchmarkEncodeTextFields(b *testing.B) {
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		be := benchmarkElement().WithFields(String("user", "bob"), Int("uid", 42), Duration("elapsed", 3*time.Millisecond), Bool("cached", true))
+		be.appendData(buf[:0], false)
+	}
+}
+
+func benchmarkLogger() *Logger {
+	return New(
+		EnableOutputConsole(true),
+		EnableOutputConsoleInJSONFormat(true),
+		ConsoleOutput(ioutil.Discard),
+		LogLevels(LogLevelInfo),
+	)
+}
+
+// both the key-value parameters and the typed fields are stored in the ordered fields on the console path
+
+func BenchmarkLoggerWithKeyValues(b *testing.B) {
+	lg := benchmarkLogger()
+	defer lg.Shutdown()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lg.With("user", "bob").With("uid", 42).Info("request completed")
+	}
+}
+
+func BenchmarkLoggerWithFields(b *testing.B) {
+	lg := benchmarkLogger()
+	defer lg.Shutdown()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lg.WithFields(String("user", "bob"), Int("uid", 42)).Info("request completed")
+	}
+}
+
+type discardHandler struct{}
+
+func (discardHandler) WriteOutTransaction(*Transaction) {}
+func (discardHandler) FlushTransactions()               {}
+
+// BenchmarkLoggerWithFieldsTransport measures the fields copied into the Data map for the custom transports
+func BenchmarkLoggerWithFieldsTransport(b *testing.B) {
+	lg := New(
+		LogLevels(LogLevelInfo),
+		TransactionTimeout(10*time.Millisecond),
+		Transports(func(list TransactionList) []Transport {
+			return []Transport{WrapTransport(list, discardHandler{})}
+		}),
+	)
+	defer lg.Shutdown()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lg.WithFields(String("user", "bob"), Int("uid", 42)).Info("request completed")
+	}
+}
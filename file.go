@@ -2,12 +2,25 @@ package loge
 
 import (
 	"bufio"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fileRetryMinDelay = time.Second
+	fileRetryMaxDelay = time.Minute
 )
 
 type fileOutputTransport struct {
+	dropped uint64 // records dropped because the file output was unavailable
+
 	buffer          TransactionList
 	currentFilename string
 	file            *os.File
@@ -19,42 +32,134 @@ type fileOutputTransport struct {
 	filename string
 	rotation bool
 	json     bool
+	sorted   bool
+	data     bool // optional data in the text format
+	filters  []Filter
+
+	schedule    *rotationSchedule
+	periodStart time.Time // start of the current rotation period
+	periodEnd   time.Time // end of the current rotation period
+
+	maxSize  int64  // file size limit in bytes (0 means unlimited)
+	size     int64  // bytes written to the current file
+	baseName string // current rotation period file name without the index
+	index    int    // current file index within the rotation period
+
+	maxBackups int           // number of rotated files to keep (0 means unlimited)
+	maxAge     time.Duration // rotated files age limit (0 means unlimited)
+
+	compressor      Compressor      // rotated files compressor (nil disables compression)
+	compressing     map[string]bool // files queued or being compressed
+	compressQueue   []compressJob
+	compressRunning bool
+	compressLock    sync.Mutex // guards the compression queue and the backups renaming
+	compressWG      sync.WaitGroup
+	compressed      chan struct{}
+	started         bool
 
-	terminated bool
+	reopenRequested uint32
 
-	signal chan struct{}
+	errorHandler func(error)
+	retryDelay   time.Duration  // current retry backoff (0 if the output is healthy)
+	retryTimer   *time.Timer    // pending retry (nil if none)
+	pending      map[uint64]int // number of records in transactions held during the outage
+	progress     map[uint64]int // number of records of the partially written transactions already in the file
+
+	dirMode   os.FileMode // mode of the created directories (0 disables directories creation)
+	fileMode  os.FileMode // mode of the created files (0 means default 0666 with umask applied)
+	fileOwner *fileOwner  // owner of the created files (nil keeps the process owner)
+	link      string      // name of the symlink pointing to the active file (empty disables the symlink)
+
+	syncPolicy SyncPolicy
+	syncTimer  *time.Timer // pending interval sync (nil if none)
+	unsynced   int         // transactions written since the last sync
+
+	signal       chan struct{}
+	drainRequest chan chan struct{}
 
 	trans       []uint64
 	transLocker sync.Mutex
 }
 
-func newFileTransport(buffer TransactionList, path string, filename string, rotation bool, json bool) *fileOutputTransport {
+func newFileTransport(buffer TransactionList, c *configuration) *fileOutputTransport {
 	ft := &fileOutputTransport{
 		buffer:   buffer,
 		done:     make(chan struct{}),
 		signal:   make(chan struct{}, 1),
 		trans:    make([]uint64, 0),
-		path:     path,
-		filename: filename,
-		rotation: rotation,
-		json:     json,
+		path:     c.Path,
+		filename: c.Filename,
+		rotation: (c.Mode & outputFileRotate) != 0,
+		json:     (c.Mode & outputConsoleInJSONFormat) != 0,
+		sorted:   (c.Mode & outputSortedFields) != 0,
+		data:     (c.Mode & outputConsoleOptionalData) != 0,
+		filters:  c.FileFilters,
+		maxSize:  c.MaxFileSize,
+
+		drainRequest: make(chan chan struct{}),
+
+		maxBackups: c.MaxBackups,
+		maxAge:     c.MaxAge,
+
+		compressor:  c.Compressor,
+		compressing: make(map[string]bool),
+		compressed:  make(chan struct{}, 1),
+
+		errorHandler: c.ErrorHandler,
+		pending:      make(map[uint64]int),
+		progress:     make(map[uint64]int),
+		syncPolicy:   c.FileSync,
+
+		dirMode:   c.DirMode,
+		fileMode:  c.FileMode,
+		fileOwner: c.fileOwner,
+		link:      c.CurrentLink,
+	}
+
+	if ft.rotation {
+		var err error
+		ft.schedule, err = newRotationSchedule(c.Filename, c.FilePattern, c.RotationInterval, c.RotationLocation)
+		if err != nil {
+			os.Stderr.Write([]byte("Log file pattern is invalid.  Default file pattern is used.\n"))
+			ft.schedule, _ = newRotationSchedule(c.Filename, defaultPattern(c.RotationInterval), c.RotationInterval, c.RotationLocation)
+		}
 	}
 
+	ft.wg.Add(1)
 	go ft.loop()
 	return ft
 }
 
 func (ft *fileOutputTransport) loop() {
-	ft.wg.Add(1)
 	defer ft.wg.Done()
 
 	for {
+		var retry, commit <-chan time.Time
+		if ft.retryTimer != nil {
+			retry = ft.retryTimer.C
+		}
+		if ft.syncTimer != nil {
+			commit = ft.syncTimer.C
+		}
+
 		select {
 		case <-ft.done:
-			ft.flushAll()
+			ft.flushAll(true)
+			ft.sync()
 			return
+		case done := <-ft.drainRequest:
+			ft.flushAll(false)
+			close(done)
 		case <-ft.signal:
-			ft.flushAll()
+			ft.flushAll(false)
+		case <-retry:
+			ft.retryTimer = nil
+			ft.flushAll(false)
+		case <-commit:
+			ft.syncTimer = nil
+			ft.sync()
+		case <-ft.compressed:
+			ft.removeExpired()
 		}
 	}
 }
@@ -70,31 +175,51 @@ func (ft *fileOutputTransport) NewTransaction(id uint64) {
 	}
 }
 
+// Drain blocks until all the transactions received so far are written out
+func (ft *fileOutputTransport) Drain() {
+	done := make(chan struct{})
+	select {
+	case ft.drainRequest <- done:
+		<-done
+	case <-ft.done:
+	}
+}
+
 func (ft *fileOutputTransport) Stop() {
 	close(ft.done)
 	ft.wg.Wait()
+
+	if ft.compressor != nil {
+		ft.compressWG.Wait()
+		ft.removeExpired()
+	}
 }
 
-func (ft *fileOutputTransport) flushAll() {
-	if ft.terminated {
+// flushAll writes out all pending transactions.  If the file can't be written the transactions are kept
+// pending and the write is retried with backoff, the final flush at Stop drops whatever is left.
+func (ft *fileOutputTransport) flushAll(final bool) {
+	if (ft.retryTimer != nil) && !final {
 		return
 	}
 
 	if ft.file != nil {
 		if ft.rotation {
-			if ft.currentFilename != getLogName(ft.path) {
-				ft.file.Close()
-				ft.file = nil
-				ft.writer = nil
+			if !time.Now().Before(ft.periodEnd) {
+				ft.closeFile()
+				ft.compress(ft.currentFilename)
 			}
 		}
 	}
 
+	if ft.file != nil {
+		if (atomic.SwapUint32(&ft.reopenRequested, 0) != 0) || ft.fileMoved() {
+			ft.closeFile()
+		}
+	}
+
 	if ft.file == nil {
-		ft.createFile()
-		if ft.file == nil {
-			ft.terminated = true
-			os.Stderr.Write([]byte("Unable to create the output file.  Log file output is disabled.\n"))
+		if err := ft.createFile(); err != nil {
+			ft.fail(err, final)
 			return
 		}
 	}
@@ -102,6 +227,7 @@ func (ft *fileOutputTransport) flushAll() {
 	ft.transLocker.Lock()
 	if len(ft.trans) == 0 {
 		ft.transLocker.Unlock()
+		ft.recover()
 		return
 	}
 
@@ -109,41 +235,453 @@ func (ft *fileOutputTransport) flushAll() {
 	ft.trans = make([]uint64, 0)
 	ft.transLocker.Unlock()
 
+	for i, id := range ids {
+		tr, ok := ft.buffer.Get(id, false)
+		if !ok {
+			// transaction has expired from the backlog while the file was unavailable
+			atomic.AddUint64(&ft.dropped, uint64(ft.pending[id]))
+			delete(ft.pending, id)
+			delete(ft.progress, id)
+			continue
+		}
+
+		written, err := ft.writeTransaction(filterTransaction(tr, ft.filters), ft.progress[id])
+		if err != nil {
+			// the retry resumes from the first record not written to the file
+			ft.progress[id] = written
+
+			ft.transLocker.Lock()
+			ft.trans = append(ids[i:], ft.trans...)
+			ft.transLocker.Unlock()
+
+			ft.fail(err, final)
+			return
+		}
+
+		ft.buffer.Free(id)
+		delete(ft.pending, id)
+		delete(ft.progress, id)
+		ft.written()
+	}
+
+	ft.recover()
+}
+
+// writeTransaction writes the records of the transaction starting from the first record not written yet and returns
+// the number of records written to the file, a record torn by the error is written again on retry
+func (ft *fileOutputTransport) writeTransaction(tr *Transaction, from int) (int, error) {
+	written := from
+	var accepted int64 // bytes accepted by the writer since the rollover
+	var ends []int64   // end offsets of the records following the written ones
+
+	// flushed returns the number of records completely passed from the writer to the file
+	flushed := func() int {
+		committed := accepted - int64(ft.writer.Buffered())
+		n := 0
+		for (n < len(ends)) && (ends[n] <= committed) {
+			n++
+		}
+		return written + n
+	}
+
+	for i := from; i < len(tr.Items); i++ {
+		be := tr.Items[i]
+		var record []byte
+		if ft.json {
+			var err error
+			record, err = be.appendJSON(make([]byte, 0, len(be.Message)+256), ft.sorted)
+			if err != nil {
+				ends = append(ends, accepted)
+				continue
+			}
+			record = append(record, '\n')
+		} else {
+			record = make([]byte, 0, len(be.Timestring)+len(be.Logger)+len(be.Message)+len(be.Stack)+4)
+			record = append(record, be.Timestring[:]...)
+			if be.Logger != "" {
+				record = append(record, '[')
+				record = append(record, be.Logger...)
+				record = append(record, "] "...)
+			}
+			if be.File != "" {
+				record = append(record, be.caller()...)
+			}
+			if ft.data {
+				record = be.appendData(record, ft.sorted)
+			}
+			record = append(record, be.Message...)
+			record = append(record, '\n')
+			record = append(record, be.Stack...)
+		}
+
+		if (ft.maxSize > 0) && (ft.size > 0) && (ft.size+int64(len(record)) > ft.maxSize) {
+			if err := ft.writer.Flush(); err != nil {
+				return flushed(), err
+			}
+			written, accepted, ends = i, 0, nil
+			if err := ft.rollover(); err != nil {
+				return written, err
+			}
+		}
+
+		n, err := ft.writer.Write(record)
+		ft.size += int64(n)
+		accepted += int64(n)
+		ends = append(ends, accepted+int64(len(record)-n))
+		if err != nil {
+			return flushed(), err
+		}
+	}
+
+	if err := ft.writer.Flush(); err != nil {
+		return flushed(), err
+	}
+
+	return len(tr.Items), nil
+}
+
+// fail reports the error, releases the broken file and schedules the next attempt
+func (ft *fileOutputTransport) fail(err error, final bool) {
+	if ft.errorHandler != nil {
+		ft.errorHandler(err)
+	} else if ft.retryDelay == 0 {
+		os.Stderr.Write([]byte("Unable to write the output file: " + err.Error() + ".  Log file output is suspended.\n"))
+	}
+
+	ft.unsynced = 0
+
+	if ft.file != nil {
+		ft.file.Close()
+		ft.file = nil
+		ft.writer = nil
+	}
+
+	ft.transLocker.Lock()
+	ids := ft.trans
+	if final {
+		ft.trans = make([]uint64, 0)
+	}
+	ft.transLocker.Unlock()
+
 	for _, id := range ids {
-		tr, ok := ft.buffer.Get(id, true)
-		if ok {
-			for _, be := range tr.Items {
-				if ft.json {
-					json, err := be.Marshal()
-					if err == nil {
-						ft.writer.Write(json)
-						ft.writer.Write([]byte("\n"))
-					}
-				} else {
-					ft.writer.Write(be.Timestring[:])
-					ft.writer.Write([]byte(be.Message))
-					ft.writer.Write([]byte("\n"))
-				}
+		if final {
+			// nothing is going to deliver pending transactions after the transport is stopped
+			if tr, ok := ft.buffer.Get(id, true); ok {
+				atomic.AddUint64(&ft.dropped, uint64(len(filterTransaction(tr, ft.filters).Items)-ft.progress[id]))
 			}
+			delete(ft.progress, id)
+			continue
+		}
+
+		// the records already in the file are not lost with the transaction
+		if tr, ok := ft.buffer.Get(id, false); ok {
+			ft.pending[id] = len(filterTransaction(tr, ft.filters).Items) - ft.progress[id]
+		}
+	}
+
+	if final {
+		return
+	}
+
+	if ft.retryDelay == 0 {
+		ft.retryDelay = fileRetryMinDelay
+	} else {
+		ft.retryDelay *= 2
+		if ft.retryDelay > fileRetryMaxDelay {
+			ft.retryDelay = fileRetryMaxDelay
 		}
 	}
 
+	ft.retryTimer = time.NewTimer(ft.retryDelay)
+}
+
+// recover resets the retry backoff after a successful write
+func (ft *fileOutputTransport) recover() {
+	if ft.retryDelay != 0 {
+		ft.retryDelay = 0
+		if ft.errorHandler == nil {
+			os.Stderr.Write([]byte("Log file output is restored.\n"))
+		}
+	}
+}
+
+func (ft *fileOutputTransport) closeFile() {
 	ft.writer.Flush()
+	if ft.syncPolicy.enabled() && (ft.unsynced > 0) {
+		ft.sync()
+	}
+	ft.file.Close()
+	ft.file = nil
+	ft.writer = nil
+}
+
+// rollover closes the current file once it has reached the size limit and opens the next one.
+// With rotation enabled the next file of the same period gets an incremented index (YYYYMMDD.N.log),
+// otherwise the existing file is renamed to name.1 shifting older backups (name.1 to name.2 etc).
+func (ft *fileOutputTransport) rollover() error {
+	ft.closeFile()
+
+	if ft.rotation {
+		ft.compress(ft.currentFilename)
+		ft.index++
+	} else if ft.compressor != nil {
+		// backups are shifted by the compression goroutine as they can't be renamed while being compressed
+		if err := ft.stage(ft.currentFilename); err != nil {
+			ft.reportError(err)
+		}
+	} else {
+		shiftBackups(ft.currentFilename, ft.currentFilename, "")
+	}
+
+	return ft.createFile()
 }
 
-func (ft *fileOutputTransport) createFile() {
+func (ft *fileOutputTransport) createFile() error {
 	if ft.rotation {
-		ft.currentFilename = getLogName(ft.path)
+		start := ft.schedule.periodStart(time.Now())
+		ft.periodEnd = ft.schedule.periodEnd(start)
+
+		base := filepath.Join(ft.path, ft.schedule.name(start))
+		if base != ft.baseName {
+			ft.baseName = base
+			ft.index = lastLogIndex(base, ft.compressedExtension(), ft.maxSize)
+		} else if !start.Equal(ft.periodStart) {
+			// the file of the previous period with the same name (the repeated hour at the end of daylight saving time)
+			// is closed and compressed, the new period continues with the next index
+			ft.index++
+		}
+		ft.periodStart = start
+		ft.currentFilename = indexedLogName(base, ft.index)
 	} else {
 		ft.currentFilename = filepath.Join(ft.path, ft.filename)
 	}
 
 	var err error
-	ft.file, err = os.OpenFile(ft.currentFilename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	ft.file, err = ft.openFile(ft.currentFilename, os.O_RDWR|os.O_APPEND)
 	if err != nil {
 		ft.file = nil
-		return
+		return err
+	}
+
+	ft.size = 0
+	if info, err := ft.file.Stat(); err == nil {
+		ft.size = info.Size()
 	}
 
 	ft.writer = bufio.NewWriter(ft.file)
+
+	ft.updateLink()
+	ft.removeExpired()
+
+	if !ft.started {
+		ft.started = true
+		ft.recoverCompression()
+	}
+
+	return nil
+}
+
+func (ft *fileOutputTransport) compressedExtension() string {
+	if ft.compressor == nil {
+		return ""
+	}
+	return ft.compressor.Extension()
+}
+
+// isBackup reports whether the file name was produced by the transport for a rotated or rolled over file
+func (ft *fileOutputTransport) isBackup(name string) bool {
+	if ext := ft.compressedExtension(); ext != "" {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	if ft.rotation {
+		return ft.schedule.match(name)
+	}
+
+	if !strings.HasPrefix(name, ft.filename+".") {
+		return false
+	}
+
+	return isNumber(strings.TrimPrefix(name, ft.filename+"."))
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < '0') || (c > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// removeExpired enforces the retention policy deleting the oldest files created by the transport
+func (ft *fileOutputTransport) removeExpired() {
+	if (ft.maxBackups <= 0) && (ft.maxAge <= 0) {
+		return
+	}
+
+	// backups are not renamed by the compression goroutine while the expired files are removed
+	ft.compressLock.Lock()
+	defer ft.compressLock.Unlock()
+
+	entries, err := ioutil.ReadDir(ft.path)
+	if err != nil {
+		return
+	}
+
+	current := filepath.Base(ft.currentFilename)
+	backups := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || ((entry.Mode() & os.ModeSymlink) != 0) || (entry.Name() == current) || !ft.isBackup(entry.Name()) {
+			continue
+		}
+		backups = append(backups, entry)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+
+	cutoff := time.Now().Add(-ft.maxAge)
+	for i, info := range backups {
+		if ((ft.maxBackups > 0) && (i >= ft.maxBackups)) || ((ft.maxAge > 0) && info.ModTime().Before(cutoff)) {
+			name := filepath.Join(ft.path, info.Name())
+			if !ft.compressing[name] {
+				os.Remove(name)
+			}
+		}
+	}
+}
+
+// openFile opens the file creating the missing directories if configured.  The configured mode and owner
+// are applied to the newly created files only.
+func (ft *fileOutputTransport) openFile(name string, flag int) (*os.File, error) {
+	if ft.dirMode != 0 {
+		if err := os.MkdirAll(filepath.Dir(name), ft.dirMode); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := ft.fileMode
+	if mode == 0 {
+		mode = 0666
+	}
+
+	_, err := os.Stat(name)
+	created := os.IsNotExist(err)
+
+	f, err := os.OpenFile(name, flag|os.O_CREATE, mode)
+	if (err != nil) || !created {
+		return f, err
+	}
+
+	if ft.fileMode != 0 {
+		// the exact mode is not affected by umask
+		if err := f.Chmod(ft.fileMode); err != nil {
+			ft.reportError(err)
+		}
+	}
+
+	if ft.fileOwner != nil {
+		if err := f.Chown(ft.fileOwner.uid, ft.fileOwner.gid); err != nil {
+			ft.reportError(err)
+		}
+	}
+
+	return f, nil
+}
+
+// indexedLogName inserts the file index before the extension: YYYYMMDD.log -> YYYYMMDD.N.log
+func indexedLogName(name string, index int) string {
+	if index == 0 {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + strconv.Itoa(index) + ext
+}
+
+// lastLogIndex finds the index of the file to continue writing to within the rotation period: the highest index
+// present in the directory (the lower indices might be removed by the retention policy) or the next one if the file
+// is full or compressed, compressed files are never reopened
+func lastLogIndex(name string, ext string, maxSize int64) int {
+	entries, err := ioutil.ReadDir(filepath.Dir(name))
+	if err != nil {
+		return 0
+	}
+
+	base := filepath.Base(name)
+	suffix := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, suffix) + "."
+
+	index := -1
+	full := false
+	for _, entry := range entries {
+		file := entry.Name()
+		compressed := (ext != "") && strings.HasSuffix(file, ext)
+		file = strings.TrimSuffix(file, ext)
+
+		i := 0
+		if file != base {
+			number := strings.TrimSuffix(strings.TrimPrefix(file, stem), suffix)
+			if (len(number) != len(file)-len(stem)-len(suffix)) || !isNumber(number) {
+				continue
+			}
+			i, _ = strconv.Atoi(number)
+		}
+
+		if i > index {
+			index = i
+			full = false
+		}
+		if i == index {
+			full = full || compressed || ((maxSize > 0) && (entry.Size() >= maxSize))
+		}
+	}
+
+	if index < 0 {
+		return 0
+	}
+
+	if full {
+		return index + 1
+	}
+
+	return index
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func backupName(name string, index int) string {
+	return name + "." + strconv.Itoa(index)
+}
+
+// shiftBackups renames src to name.1 moving every existing numbered backup (plain or compressed)
+// one position up
+func shiftBackups(name string, src string, ext string) {
+	last := 0
+	for {
+		next := backupName(name, last+1)
+		if !fileExists(next) && ((ext == "") || !fileExists(next+ext)) {
+			break
+		}
+		last++
+	}
+
+	for i := last; i > 0; i-- {
+		os.Rename(backupName(name, i), backupName(name, i+1))
+		if ext != "" {
+			os.Rename(backupName(name, i)+ext, backupName(name, i+1)+ext)
+		}
+	}
+
+	os.Rename(src, backupName(name, 1))
 }
@@ -0,0 +1,243 @@
+package loge
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastLogIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]int // file name and size
+		maxSize int64
+		want    int
+	}{
+		{"empty", nil, 100, 0},
+		{"current", map[string]int{"app-20261017.log": 10}, 100, 0},
+		{"current full", map[string]int{"app-20261017.log": 100}, 100, 1},
+		{"unlimited", map[string]int{"app-20261017.log": 100}, 0, 0},
+		{"indexed", map[string]int{"app-20261017.log": 100, "app-20261017.1.log": 100, "app-20261017.2.log": 10}, 100, 2},
+		{"compressed", map[string]int{"app-20261017.log.gz": 10, "app-20261017.1.log.gz": 10}, 100, 2},
+		{"compressed unlimited", map[string]int{"app-20261017.log.gz": 10}, 0, 1},
+		{"pruned", map[string]int{"app-20261017.14.log.gz": 10, "app-20261017.15.log.gz": 10, "app-20261017.16.log.gz": 10}, 100, 17},
+		{"pruned plain", map[string]int{"app-20261017.15.log.gz": 10, "app-20261017.16.log": 10}, 100, 16},
+		{"other files", map[string]int{"app-20261016.3.log": 10, "app-20261017.x.log": 10, "app-20261017.log.tmp": 10}, 100, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "loge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, size := range test.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, size), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := lastLogIndex(filepath.Join(dir, "app-20261017.log"), ".gz", test.maxSize); got != test.want {
+				t.Errorf("lastLogIndex() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// readLogLines returns the lines of all the log files in the directory decompressing the gzip files
+func readLogLines(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, info := range files {
+		f, err := os.Open(filepath.Join(dir, info.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var r io.Reader = f
+		if strings.HasSuffix(info.Name(), ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		} else if !strings.HasSuffix(info.Name(), ".log") {
+			t.Errorf("unexpected file %s", info.Name())
+		}
+
+		data, err := ioutil.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	return lines
+}
+
+func TestRotationKeepsAllRecords(t *testing.T) {
+	for _, pattern := range []string{"", "{date:20060102}.log"} {
+		dir, err := ioutil.TempDir("", "loge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		lg := New(
+			EnableOutputFile(true),
+			EnableFileRotate(true),
+			Path(dir),
+			Filename("app-"),
+			FilePattern(pattern),
+			RotationInterval(time.Second),
+			Compression(Gzip()),
+			TransactionTimeout(10*time.Millisecond),
+		)
+
+		const records = 40
+		for i := 0; i < records; i++ {
+			lg.Printf("record %d", i)
+			time.Sleep(50 * time.Millisecond)
+		}
+		lg.Shutdown()
+
+		if lines := readLogLines(t, dir); len(lines) != records {
+			t.Errorf("pattern %q: %d records on disk, want %d", pattern, len(lines), records)
+		}
+	}
+}
+
+func TestRotationRepeatedName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// consecutive periods with the same name like the repeated hour at the end of daylight saving time
+	ft := &fileOutputTransport{
+		path:     dir,
+		rotation: true,
+		schedule: &rotationSchedule{prefix: "app-", layout: "20060102", after: ".log", interval: time.Second, location: time.UTC},
+	}
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		if err := ft.createFile(); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.Base(ft.currentFilename))
+		ft.closeFile()
+		time.Sleep(time.Until(ft.periodEnd))
+	}
+
+	if names[0] == names[1] {
+		t.Errorf("periods are written to the same file %s", names[0])
+	}
+}
+
+// limitedWriter accepts limit bytes and fails the following writes
+type limitedWriter struct {
+	data  []byte
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(w.data)+n > w.limit {
+		n = w.limit - len(w.data)
+	}
+	w.data = append(w.data, p[:n]...)
+	if n < len(p) {
+		return n, errors.New("disk full")
+	}
+	return n, nil
+}
+
+func TestWriteTransactionResumes(t *testing.T) {
+	tr := &Transaction{}
+	for i := 0; i < 10; i++ {
+		tr.Items = append(tr.Items, NewBufferElement(time.Now(), nil, []byte(fmt.Sprintf("record %d", i)), LogLevelInfo))
+	}
+
+	// the file takes the first records and a part of the next one
+	w := &limitedWriter{limit: 200}
+	ft := &fileOutputTransport{writer: bufio.NewWriterSize(w, 64)}
+
+	written, err := ft.writeTransaction(tr, 0)
+	if err == nil {
+		t.Fatal("writeTransaction() succeeded on the full disk")
+	}
+	if (written == 0) || (written == len(tr.Items)) {
+		t.Fatalf("writeTransaction() = %d records written", written)
+	}
+
+	complete := len(strings.SplitAfter(string(w.data), "\n")) - 1
+	if complete != written {
+		t.Fatalf("writeTransaction() = %d records written, %d records in the file", written, complete)
+	}
+
+	// the torn record is cut off to compare the records
+	w.data, w.limit = w.data[:strings.LastIndex(string(w.data), "\n")+1], 1<<20
+	ft.writer = bufio.NewWriterSize(w, 64)
+	if written, err = ft.writeTransaction(tr, written); (err != nil) || (written != len(tr.Items)) {
+		t.Fatalf("writeTransaction() retry = %d, %v", written, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(w.data), "\n"), "\n")
+	if len(lines) != len(tr.Items) {
+		t.Fatalf("%d records in the file, want %d", len(lines), len(tr.Items))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, fmt.Sprintf("record %d", i)) {
+			t.Errorf("record %d = %q", i, line)
+		}
+	}
+}
+
+func TestFileOptionalData(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "loge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		lg := New(
+			LogLevels(LogLevelInfo),
+			EnableOutputFile(true),
+			EnableOutputConsoleOptionalData(enabled),
+			Path(dir),
+			Filename("app.log"),
+		)
+		lg.With("uid", 42).WithFields(String("user", "bob")).Info("request completed")
+		lg.Shutdown()
+
+		lines := readLogLines(t, dir)
+		if len(lines) != 1 {
+			t.Fatalf("%d records on disk, want 1", len(lines))
+		}
+
+		if written := strings.Contains(lines[0], "<uid: 42, user: bob> request completed"); written != enabled {
+			t.Errorf("optional data %v: record %q", enabled, lines[0])
+		}
+	}
+}
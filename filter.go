@@ -0,0 +1,89 @@
+package loge
+
+import (
+	"reflect"
+)
+
+// Filter selects the records delivered to a transport, see WrapTransport and FileFilter
+type Filter func(be *BufferElement) bool
+
+// LevelFilter returns a filter accepting the records of the levels bitmask (LogLevelPlain for the records without a level),
+// e.g. LogLevelWarning|LogLevelError|LogLevelFatal|LogLevelPanic
+func LevelFilter(levels uint32) Filter {
+	return func(be *BufferElement) bool {
+		if be.Level == 0 {
+			return (levels & LogLevelPlain) != 0
+		}
+		return (be.Level & levels) != 0
+	}
+}
+
+// FieldFilter returns a filter accepting the records with the key, if the value is not nil the record value must be equal to it
+func FieldFilter(key string, value interface{}) Filter {
+	want := filterValue(value)
+	return func(be *BufferElement) bool {
+		v, ok := be.Lookup(key)
+		if !ok {
+			return false
+		}
+		return (value == nil) || reflect.DeepEqual(filterValue(v), want)
+	}
+}
+
+// filterValue returns the value compared by FieldFilter, int values are compared as int64
+func filterValue(value interface{}) interface{} {
+	f := Any("", value)
+	if f.Type == FieldInt {
+		return f.integer
+	}
+	return f.Value()
+}
+
+// Lookup returns the value of the field or the Data value of the key
+func (be *BufferElement) Lookup(key string) (interface{}, bool) {
+	for i := len(be.Fields) - 1; i >= 0; i-- {
+		if be.Fields[i].Key == key {
+			return be.Fields[i].Value(), true
+		}
+	}
+
+	v, ok := be.Data[key]
+	return v, ok
+}
+
+// filterTransaction returns the transaction with the records accepted by all the filters,
+// the transaction is copied if any record is rejected as it is shared between the transports
+func filterTransaction(tr *Transaction, filters []Filter) *Transaction {
+	if len(filters) == 0 {
+		return tr
+	}
+
+	var items []*BufferElement
+	for i, be := range tr.Items {
+		if accept(be, filters) {
+			if items != nil {
+				items = append(items, be)
+			}
+			continue
+		}
+
+		if items == nil {
+			items = make([]*BufferElement, i, len(tr.Items))
+			copy(items, tr.Items[:i])
+		}
+	}
+
+	if items == nil {
+		return tr
+	}
+	return &Transaction{ID: tr.ID, Items: items}
+}
+
+func accept(be *BufferElement, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(be) {
+			return false
+		}
+	}
+	return true
+}
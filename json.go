@@ -0,0 +1,150 @@
+package loge
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSON appends the record encoded in the same way as encoding/json would encode the BufferElement,
+// the fields are followed by the Data values in the "data" object, see eachData
+func (be *BufferElement) appendJSON(buf []byte, sorted bool) ([]byte, error) {
+	buf = append(buf, `{"time":"`...)
+	buf = be.Timestamp.AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","msg":`...)
+	buf = appendJSONString(buf, be.Message)
+	if be.Levelstring != "" {
+		buf = append(buf, `,"level":`...)
+		buf = appendJSONString(buf, be.Levelstring)
+	}
+	if be.Logger != "" {
+		buf = append(buf, `,"logger":`...)
+		buf = appendJSONString(buf, be.Logger)
+	}
+	if be.File != "" {
+		buf = append(buf, `,"file":`...)
+		buf = appendJSONString(buf, be.File)
+	}
+	if be.Line != 0 {
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendInt(buf, int64(be.Line), 10)
+	}
+	if be.Function != "" {
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONString(buf, be.Function)
+	}
+	if be.Stack != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, be.Stack)
+	}
+
+	if be.hasData() {
+		var err error
+		buf = append(buf, `,"data":{`...)
+		first := true
+		be.eachData(sorted, func(key string, f *Field, value interface{}) bool {
+			if !first {
+				buf = append(buf, ',')
+			}
+			first = false
+			buf = appendJSONString(buf, key)
+			buf = append(buf, ':')
+			if f != nil {
+				buf, err = f.appendJSON(buf, sorted)
+			} else {
+				buf, err = appendJSONValue(buf, value)
+			}
+			return err == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, '}')
+	}
+
+	return append(buf, '}'), nil
+}
+
+// appendJSONValue appends the value encoded with encoding/json, error values are encoded as their messages
+func appendJSONValue(buf []byte, v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case string:
+		return appendJSONString(buf, t), nil
+	case int:
+		return strconv.AppendInt(buf, int64(t), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, t, 10), nil
+	case bool:
+		return strconv.AppendBool(buf, t), nil
+	case json.Marshaler:
+	case error:
+		return appendJSONString(buf, t.Error()), nil
+	case map[string]interface{}:
+		if hasErrors(t) {
+			v = replaceErrors(t)
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, data...), nil
+}
+
+// appendJSONString appends the quoted string escaped in the same way as encoding/json
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if (b >= 0x20) && (b != '"') && (b != '\\') && (b != '<') && (b != '>') && (b != '&') {
+				i++
+				continue
+			}
+
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if (c == utf8.RuneError) && (size == 1) {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 are valid JSON but break JavaScript
+		if (c == '\u2028') || (c == '\u2029') {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
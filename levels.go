@@ -0,0 +1,292 @@
+package loge
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var levelNames = []struct {
+	level uint32
+	name  string
+}{
+	{LogLevelInfo, "info"},
+	{LogLevelDebug, "debug"},
+	{LogLevelTrace, "trace"},
+	{LogLevelWarning, "warning"},
+	{LogLevelError, "error"},
+}
+
+// SetLevels replaces the selectable log levels of the default logger at runtime
+func SetLevels(mask uint32) {
+	std.SetLevels(mask)
+}
+
+// GetLevels returns the selectable log levels of the default logger
+func GetLevels() uint32 {
+	return std.GetLevels()
+}
+
+// SetLevels replaces the selectable log levels at runtime
+func (lg *Logger) SetLevels(mask uint32) {
+	atomic.StoreUint32(&lg.core.levels, mask)
+	atomic.AddUint32(&lg.core.levelsGeneration, 1)
+}
+
+// GetLevels returns the selectable log levels
+func (lg *Logger) GetLevels() uint32 {
+	return atomic.LoadUint32(&lg.core.levels)
+}
+
+func (lg *Logger) enabled(level uint32) bool {
+	return (lg.levels() & level) != 0
+}
+
+// levels returns the log levels of the logger, levels of the named loggers are cached until
+// the rules or the default levels are changed
+func (lg *Logger) levels() uint32 {
+	if lg.name == "" {
+		return atomic.LoadUint32(&lg.core.levels)
+	}
+
+	// generation is stored incremented so the zero cache value is never valid
+	generation := atomic.LoadUint32(&lg.core.levelsGeneration) + 1
+	cached := atomic.LoadUint64(&lg.levelCache)
+	if uint32(cached>>32) == generation {
+		return uint32(cached)
+	}
+
+	mask := lg.core.resolveLevels(lg.name)
+	atomic.StoreUint64(&lg.levelCache, (uint64(generation)<<32)|uint64(mask))
+	return mask
+}
+
+// resolveLevels finds the levels of the closest configured component: "db.pool.conn", "db.pool", "db"
+func (l *logger) resolveLevels(name string) uint32 {
+	l.levelRulesLock.RLock()
+	defer l.levelRulesLock.RUnlock()
+
+	for {
+		if mask, ok := l.levelRules[name]; ok {
+			return mask
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return atomic.LoadUint32(&l.levels)
+		}
+		name = name[:i]
+	}
+}
+
+// Named returns a logger for the named component of the default logger
+func Named(name string) *Logger {
+	return std.Named(name)
+}
+
+// SetLoggerLevels sets the log levels of the named component of the default logger at runtime
+func SetLoggerLevels(name string, mask uint32) {
+	std.SetLoggerLevels(name, mask)
+}
+
+// SetLevelSpec replaces the named components levels of the default logger at runtime, see LevelSpec
+func SetLevelSpec(spec string) error {
+	return std.SetLevelSpec(spec)
+}
+
+// SetLoggerLevels sets the log levels of the named component (and its nested components unless configured) at runtime
+func (lg *Logger) SetLoggerLevels(name string, mask uint32) {
+	lg.core.levelRulesLock.Lock()
+	lg.core.levelRules[name] = mask
+	lg.core.levelRulesLock.Unlock()
+
+	atomic.AddUint32(&lg.core.levelsGeneration, 1)
+}
+
+// SetLevelSpec replaces all the named components levels at runtime, see LevelSpec for the format
+func (lg *Logger) SetLevelSpec(spec string) error {
+	defaults, hasDefaults, rules, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	lg.core.levelRulesLock.Lock()
+	lg.core.levelRules = rules
+	lg.core.levelRulesLock.Unlock()
+
+	if hasDefaults {
+		atomic.StoreUint32(&lg.core.levels, defaults)
+	}
+
+	atomic.AddUint32(&lg.core.levelsGeneration, 1)
+	return nil
+}
+
+var errInvalidLevelSpec = errors.New("invalid log level specification")
+
+// levelSeverity lists the levels from the least to the most severe for the threshold level specification
+var levelSeverity = []uint32{LogLevelTrace, LogLevelDebug, LogLevelInfo, LogLevelWarning, LogLevelError}
+
+// parseLevels parses a single level specification: "off", a level name enabling it with all more severe
+// levels ("warn" enables warnings and errors) or a list of exact levels ("info|error")
+func parseLevels(s string) (uint32, bool) {
+	s = strings.TrimSpace(s)
+	if (s == "off") || (s == "none") {
+		return 0, true
+	}
+
+	if strings.Contains(s, "|") {
+		var mask uint32
+		for _, name := range strings.Split(s, "|") {
+			level, ok := levelByName(strings.TrimSpace(name))
+			if !ok {
+				return 0, false
+			}
+			mask |= level
+		}
+		return mask, true
+	}
+
+	level, ok := levelByName(s)
+	if !ok {
+		return 0, false
+	}
+
+	var mask uint32
+	for i := len(levelSeverity) - 1; i >= 0; i-- {
+		mask |= levelSeverity[i]
+		if levelSeverity[i] == level {
+			break
+		}
+	}
+	return mask, true
+}
+
+// parseLevelSpec parses the comma separated list of component=levels pairs, an entry without the
+// component name sets the default levels: "db.pool=trace,http=warn,info"
+func parseLevelSpec(spec string) (uint32, bool, map[string]uint32, error) {
+	var defaults uint32
+	hasDefaults := false
+	rules := make(map[string]uint32)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name := ""
+		levels := entry
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			name = strings.TrimSpace(entry[:i])
+			levels = entry[i+1:]
+			if name == "" {
+				return 0, false, nil, errInvalidLevelSpec
+			}
+		}
+
+		mask, ok := parseLevels(levels)
+		if !ok {
+			return 0, false, nil, errInvalidLevelSpec
+		}
+
+		if name == "" {
+			defaults = mask
+			hasDefaults = true
+		} else {
+			rules[name] = mask
+		}
+	}
+
+	return defaults, hasDefaults, rules, nil
+}
+
+// levelsState is a JSON representation of the log levels used by the levels HTTP handler.
+// Levels could be set either with the bitmask or with the list of level names.
+type levelsState struct {
+	Levels *uint32  `json:"levels,omitempty"`
+	Names  []string `json:"names,omitempty"`
+}
+
+func newLevelsState(mask uint32) levelsState {
+	s := levelsState{Levels: &mask, Names: make([]string, 0, len(levelNames))}
+	for _, n := range levelNames {
+		if (mask & n.level) != 0 {
+			s.Names = append(s.Names, n.name)
+		}
+	}
+	return s
+}
+
+func (s levelsState) mask() (uint32, bool) {
+	if s.Levels != nil {
+		return *s.Levels, true
+	}
+
+	if s.Names == nil {
+		return 0, false
+	}
+
+	var mask uint32
+	for _, name := range s.Names {
+		level, ok := levelByName(name)
+		if !ok {
+			return 0, false
+		}
+		mask |= level
+	}
+	return mask, true
+}
+
+func levelByName(name string) (uint32, bool) {
+	for _, n := range levelNames {
+		if n.name == name {
+			return n.level, true
+		}
+	}
+
+	if name == "warn" {
+		return LogLevelWarning, true
+	}
+
+	return 0, false
+}
+
+// LevelHandler returns an HTTP handler exposing the log levels of the default logger
+func LevelHandler() http.Handler {
+	return std.LevelHandler()
+}
+
+// LevelHandler returns an HTTP handler exposing the log levels.  GET returns the current levels
+// as {"levels":3,"names":["info","debug"]}, PUT replaces the levels with either "levels" bitmask
+// or "names" list from the request body and returns the new levels.
+func (lg *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var s levelsState
+			if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			mask, ok := s.mask()
+			if !ok {
+				http.Error(w, "invalid log levels", http.StatusBadRequest)
+				return
+			}
+
+			lg.SetLevels(mask)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newLevelsState(lg.GetLevels()))
+	})
+}
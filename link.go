@@ -0,0 +1,33 @@
+package loge
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// updateLink atomically points the current file symlink to the active log file
+func (ft *fileOutputTransport) updateLink() {
+	if ft.link == "" {
+		return
+	}
+
+	link := filepath.Join(ft.path, ft.link)
+	target := filepath.Base(ft.currentFilename)
+
+	if current, err := os.Readlink(link); (err == nil) && (current == target) {
+		return
+	}
+
+	temp := link + ".tmp"
+	os.Remove(temp)
+
+	err := os.Symlink(target, temp)
+	if err == nil {
+		err = os.Rename(temp, link)
+	}
+
+	if err != nil {
+		os.Remove(temp)
+		ft.reportError(err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,26 +18,65 @@ const (
 	LogLevelTrace   uint32 = 4
 	LogLevelWarning uint32 = 8
 	LogLevelError   uint32 = 16
+	LogLevelFatal   uint32 = 32 // always logged, flushes the outputs and exits
+	LogLevelPanic   uint32 = 64 // always logged, flushes the outputs and panics
+
+	// LogLevelPlain selects the records without a level (Printf, Println and the standard log package) in
+	// RateLimit and LevelFilter masks
+	LogLevelPlain uint32 = 1 << 31
 )
 
 // TransportCreator is an interface to create new optional transports when the log is initialized
 type TransportCreator func(TransactionList) []Transport
 
+type fileOwner struct {
+	uid int
+	gid int
+}
+
 // Configuration defines the logger startup configuration
 type configuration struct {
-	Mode                     uint32                 // work mode
-	Path                     string                 // output path for the file mode
-	Filename                 string                 // log file name (ignored if rotation is enabled)
-	TransactionSize          int                    // transaction size limit in bytes (default 10KB)
-	TransactionTimeout       time.Duration          // transaction length limit (default 3 seconds)
-	ConsoleOutput            io.Writer              // output writer for console (default os.Stderr)
-	BacklogExpirationTimeout time.Duration          // transaction backlog expiration timeout (default is time.Hour)
-	LogLevels                uint32                 // selectable log levels
-	defaultData              map[string]interface{} // default Data added to each Element
+	Mode                     uint32                    // work mode
+	Path                     string                    // output path for the file mode
+	Filename                 string                    // log file name (file name prefix if rotation is enabled)
+	FilePattern              string                    // rotated file name pattern (default {date:20060102}.log)
+	RotationInterval         time.Duration             // rotation interval (default RotateDaily)
+	RotationLocation         *time.Location            // time zone of the rotation boundaries and file names (default time.Local)
+	TransactionSize          int                       // transaction size limit in bytes (default 10KB)
+	TransactionTimeout       time.Duration             // transaction length limit (default 3 seconds)
+	ConsoleOutput            io.Writer                 // output writer for console (default os.Stderr)
+	BacklogExpirationTimeout time.Duration             // transaction backlog expiration timeout (default is time.Hour)
+	LogLevels                uint32                    // selectable log levels (initial value, see SetLevels)
+	MaxFileSize              int64                     // file size limit in bytes before the file is rolled over (0 means unlimited)
+	MaxBackups               int                       // number of rotated files to keep (0 means unlimited)
+	MaxAge                   time.Duration             // rotated files age limit (0 means unlimited)
+	Compressor               Compressor                // rotated files compressor (default nil, no compression)
+	ReopenSignals            []os.Signal               // signals reopening the log file (default nil, disabled)
+	ErrorHandler             func(error)               // output errors handler (default nil, errors are reported to os.Stderr)
+	FileSync                 SyncPolicy                // log file sync policy (default SyncNever)
+	DirMode                  os.FileMode               // mode of the created log directories (default 0, directories are not created)
+	FileMode                 os.FileMode               // mode of the created log files (default 0666 with umask applied)
+	fileOwner                *fileOwner                // owner of the created log files (default nil, process owner)
+	CurrentLink              string                    // name of the symlink pointing to the active log file (default empty, disabled)
+	ExitFunc                 func(code int)            // function terminating the process after Fatal (default os.Exit)
+	ContextExtractors        []ContextExtractor        // functions extracting key/values from the context
+	StackTraceLevels         uint32                    // levels with the stack trace attached (default 0, disabled)
+	RedactKeys               map[string]Redactor       // redactors of the lower case key names
+	RedactRules              []RedactRule              // patterns redacted in the messages and string values
+	FileFilters              []Filter                  // filters selecting the records written to the log file
+	Sampling                 SamplingPolicy            // sampling of the records with the same level and message template (default disabled)
+	loggerSampling           map[string]SamplingPolicy // sampling policies of the named components
+	rateLimits               map[uint32]rateLimit      // token bucket policies of the levels (0 for the records without a level)
+	CollapseInterval         time.Duration             // longest period of the consecutive identical records collapsed into one (default 0, disabled)
+	BufferRecords            int                       // limit of the records pending in the buffer and the backlog (default 0, unlimited)
+	BufferBytes              int                       // limit of the record bytes pending in the buffer and the backlog (default 0, unlimited)
+	Backpressure             BackpressurePolicy        // policy applied when the buffer limits are reached (default Block)
+	defaultFields            []Field                   // default data added to each Element in the order of WithDefault calls
+	loggerLevels             map[string]uint32         // log levels of the named components
 	Transports               func(list TransactionList) []Transport
 }
 
-var std *logger
+var std *Logger
 
 const (
 	outputConsole             uint32 = 1
@@ -44,15 +85,19 @@ const (
 	outputIncludeLine         uint32 = 8
 	outputConsoleInJSONFormat uint32 = 16
 	outputConsoleOptionalData uint32 = 32
+	outputStandardLog         uint32 = 64
+	outputSortedFields        uint32 = 128
 )
 
+// the default logger writes to the console and does not claim the standard log package until Init is called
 func init() {
-	std = newLogger(
-		configuration{
-			Mode:          outputConsole,
-			ConsoleOutput: os.Stderr,
-			defaultData:   make(map[string]interface{}),
-		})
+	std = &Logger{
+		core: newLogger(
+			configuration{
+				Mode:          outputConsole,
+				ConsoleOutput: os.Stderr,
+			}),
+	}
 }
 
 const (
@@ -62,24 +107,39 @@ const (
 )
 
 type logger struct {
-	configuration        configuration
-	writeTimestampBuffer []byte
-	buffer               *buffer
+	rateLimitedCount uint64 // records dropped by the rate limits, accessed atomically, first for 64-bit alignment
+
+	levels           uint32 // selectable log levels, accessed atomically
+	levelsGeneration uint32 // incremented on every levels change, accessed atomically
+	levelRules       map[string]uint32
+	levelRulesLock   sync.RWMutex
+
+	configuration configuration
+	buffer        *buffer
+	file          *fileOutputTransport
+	reopenStop    chan struct{}
+	sampler       *sampler
+	buckets       map[uint32]*tokenBucket // rate limits of the levels, never modified after the logger is created
+	repeat        repeatState
+	shutdownOnce  sync.Once
 
 	customTimestampBuffer []byte
 	customTimestampLock   sync.Mutex
 }
 
 // Init initializes the library and returns the shutdown handler to defer, must defer call the shutdown handler to ensure log messages are flushed.
+// Unlike New, Init redirects the standard log package into the library unless disabled with EnableStandardLog(false).
 func Init(decorators ...func(*configuration) *configuration) func() {
-	c := &configuration{defaultData: make(map[string]interface{})}
+	c := &configuration{
+		Mode: outputStandardLog,
+	}
 
 	for _, decorator := range decorators {
 		c = decorator(c)
 	}
 
-	std = newLogger(*c)
-	return std.shutdown
+	std = &Logger{core: newLogger(*c)}
+	return std.Shutdown
 }
 
 // Path returns a function to set the log file path.
@@ -138,6 +198,18 @@ func EnableOutputIncludeLine(enable bool) func(*configuration) *configuration {
 	}
 }
 
+// EnableStandardLog returns a function to redirect the output of the standard log package into the logger.
+func EnableStandardLog(enable bool) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if enable {
+			l.Mode |= outputStandardLog
+		} else {
+			l.Mode &^= outputStandardLog
+		}
+		return l
+	}
+}
+
 // EnableOutputConsoleInJSONFormat returns a function to enable the console output to JSON serialized format.
 func EnableOutputConsoleInJSONFormat(enable bool) func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
@@ -150,7 +222,7 @@ func EnableOutputConsoleInJSONFormat(enable bool) func(*configuration) *configur
 	}
 }
 
-// EnableOutputConsoleOptionalData returns a function to enable optional With() fields to the console output if turned on.  By default optional fields are only serialized into JSON format.
+// EnableOutputConsoleOptionalData returns a function to enable optional With() fields in the text console and file output if turned on.  By default optional fields are only serialized into JSON format.
 func EnableOutputConsoleOptionalData(enable bool) func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
 		if enable {
@@ -162,7 +234,19 @@ func EnableOutputConsoleOptionalData(enable bool) func(*configuration) *configur
 	}
 }
 
-// Filename returns a function to set the log file name (ignored if rotation is enabled).
+// EnableSortedFields returns a function to output the optional fields sorted by key instead of the insertion order.
+func EnableSortedFields(enable bool) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if enable {
+			l.Mode |= outputSortedFields
+		} else {
+			l.Mode &^= outputSortedFields
+		}
+		return l
+	}
+}
+
+// Filename returns a function to set the log file name (used as a file name prefix if rotation is enabled).
 func Filename(p string) func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
 		l.Filename = p
@@ -170,6 +254,178 @@ func Filename(p string) func(*configuration) *configuration {
 	}
 }
 
+// FilePattern returns a function to set the rotated file name pattern.  Pattern must contain a single {date:layout}
+// placeholder with the time.Format layout of the rotation period start, e.g. app-{date:2006-01-02-15}.log.
+func FilePattern(p string) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.FilePattern = p
+		return l
+	}
+}
+
+// RotationInterval returns a function to set the file rotation interval (RotateHourly, RotateDaily, RotateWeekly or custom).
+func RotationInterval(p time.Duration) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.RotationInterval = p
+		return l
+	}
+}
+
+// RotationLocation returns a function to set the time zone used to compute the rotation boundaries and file names.
+func RotationLocation(p *time.Location) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.RotationLocation = p
+		return l
+	}
+}
+
+// MaxFileSize returns a function to set the file size limit in bytes.  Once the limit is reached the file is rolled over
+// into numbered backups (name.1, name.2, ...) or into YYYYMMDD.N.log files if rotation is enabled.
+func MaxFileSize(p int64) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.MaxFileSize = p
+		return l
+	}
+}
+
+// MaxBackups returns a function to set the number of rotated or rolled over files to keep.
+func MaxBackups(p int) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.MaxBackups = p
+		return l
+	}
+}
+
+// MaxAge returns a function to set the age limit of rotated or rolled over files.
+func MaxAge(p time.Duration) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.MaxAge = p
+		return l
+	}
+}
+
+// Compression returns a function to set the compressor applied to rotated or rolled over files in background.
+func Compression(p Compressor) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.Compressor = p
+		return l
+	}
+}
+
+// ReopenOnSignal returns a function to reopen the log file when one of the signals is received (SIGHUP if none specified).
+func ReopenOnSignal(signals ...os.Signal) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.ReopenSignals = append(make([]os.Signal, 0, len(signals)), signals...)
+		return l
+	}
+}
+
+// ErrorHandler returns a function to set the handler receiving the log file output errors (by default errors are reported to os.Stderr).
+// Handler is called from the output goroutines and should not block.
+func ErrorHandler(p func(error)) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.ErrorHandler = p
+		return l
+	}
+}
+
+// FileSync returns a function to set the policy committing the log file data to the stable storage (default SyncNever).
+func FileSync(p SyncPolicy) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.FileSync = p
+		return l
+	}
+}
+
+// CreatePath returns a function to create the missing log directories with the mode.
+func CreatePath(mode os.FileMode) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.DirMode = mode
+		return l
+	}
+}
+
+// FileMode returns a function to set the mode of the created log files (default 0666 with umask applied).
+func FileMode(mode os.FileMode) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.FileMode = mode
+		return l
+	}
+}
+
+// FileOwner returns a function to set the owner of the created log files.
+func FileOwner(uid int, gid int) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.fileOwner = &fileOwner{uid: uid, gid: gid}
+		return l
+	}
+}
+
+// CurrentLink returns a function to maintain a symlink with the name in the log path pointing to the active log file.
+func CurrentLink(name string) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.CurrentLink = name
+		return l
+	}
+}
+
+// ExitFunc returns a function to set the function called to terminate the process after Fatal (default os.Exit).
+func ExitFunc(p func(code int)) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.ExitFunc = p
+		return l
+	}
+}
+
+// WithContextExtractor returns a function to add the extractor of the key/values (for example trace and span IDs)
+// from the context passed to WithContext and *Context functions.
+func WithContextExtractor(p ContextExtractor) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.ContextExtractors = append(l.ContextExtractors, p)
+		return l
+	}
+}
+
+// EnableStackTrace returns a function to attach the stack trace to the records of the levels,
+// e.g. LogLevelError|LogLevelFatal|LogLevelPanic.
+func EnableStackTrace(levels uint32) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.StackTraceLevels = levels
+		return l
+	}
+}
+
+// RedactKeys returns a function to replace the values of the keys (case insensitive) with the redactor output,
+// e.g. RedactKeys(Mask("[REDACTED]"), "password", "token").
+func RedactKeys(redactor Redactor, keys ...string) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if l.RedactKeys == nil {
+			l.RedactKeys = make(map[string]Redactor)
+		}
+		for _, key := range keys {
+			l.RedactKeys[strings.ToLower(key)] = redactor
+		}
+		return l
+	}
+}
+
+// RedactPattern returns a function to replace the pattern matches in the messages and string values with the redactor output.
+func RedactPattern(pattern *regexp.Regexp, redactor Redactor) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.RedactRules = append(l.RedactRules, RedactRule{Pattern: pattern, Redactor: redactor})
+		return l
+	}
+}
+
+// FileFilter returns a function to write only the records accepted by all the filters to the log file,
+// e.g. FileFilter(LevelFilter(LogLevelError|LogLevelFatal|LogLevelPanic)).
+func FileFilter(filters ...Filter) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.FileFilters = append(l.FileFilters, filters...)
+		return l
+	}
+}
+
 // TransactionSize returns a function to set the transaction size limit in bytes (default 10KB).
 func TransactionSize(p int) func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
@@ -210,6 +466,103 @@ func LogLevels(p uint32) func(*configuration) *configuration {
 	}
 }
 
+// LoggerLevels returns a function to set the log levels of the named component (see Named).
+func LoggerLevels(name string, mask uint32) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if l.loggerLevels == nil {
+			l.loggerLevels = make(map[string]uint32)
+		}
+		l.loggerLevels[name] = mask
+		return l
+	}
+}
+
+// Sampling returns a function to set the default sampling policy of the records with the same level and message template,
+// e.g. Sampling(SamplingPolicy{Interval: time.Second, First: 100, Thereafter: 100}).
+func Sampling(p SamplingPolicy) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.Sampling = p
+		return l
+	}
+}
+
+// LoggerSampling returns a function to set the sampling policy of the named component and its subcomponents,
+// zero policy disables the sampling for the component.
+func LoggerSampling(name string, p SamplingPolicy) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if l.loggerSampling == nil {
+			l.loggerSampling = make(map[string]SamplingPolicy)
+		}
+		l.loggerSampling[name] = p
+		return l
+	}
+}
+
+// RateLimit returns a function to limit the records of each of the levels in the mask (LogLevelPlain for the records
+// without a level) to perSecond records with bursts up to burst records, the records above the limit are dropped and
+// counted in Statistics.RateLimited.
+func RateLimit(levels uint32, perSecond float64, burst int) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		if l.rateLimits == nil {
+			l.rateLimits = make(map[uint32]rateLimit)
+		}
+		if (levels & LogLevelPlain) != 0 {
+			l.rateLimits[0] = rateLimit{rate: perSecond, burst: float64(burst)}
+		}
+		for level := uint32(1); level <= LogLevelError; level <<= 1 {
+			if (levels & level) != 0 {
+				l.rateLimits[level] = rateLimit{rate: perSecond, burst: float64(burst)}
+			}
+		}
+		return l
+	}
+}
+
+// CollapseRepeated returns a function to replace the consecutive identical records with a single
+// "last message repeated N times" record written at least once per interval.
+func CollapseRepeated(interval time.Duration) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.CollapseInterval = interval
+		return l
+	}
+}
+
+// BufferLimit returns a function to limit the records and the record bytes (0 means unlimited) pending in the buffer
+// and the transactions backlog, the policy is applied to the new records once the limit is reached.
+func BufferLimit(records int, bytes int, policy BackpressurePolicy) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		l.BufferRecords = records
+		l.BufferBytes = bytes
+		l.Backpressure = policy
+		return l
+	}
+}
+
+// LevelSpec returns a function to set the log levels of the named components from the comma separated specification
+// "db.pool=trace,http=warn,info".  A level name enables it with all more severe levels, exact levels could be listed
+// as "info|error", "off" disables all levels and an entry without the component name sets the default levels.
+func LevelSpec(spec string) func(*configuration) *configuration {
+	return func(l *configuration) *configuration {
+		defaults, hasDefaults, rules, err := parseLevelSpec(spec)
+		if err != nil {
+			os.Stderr.Write([]byte("Log level specification is invalid and ignored.\n"))
+			return l
+		}
+
+		if hasDefaults {
+			l.LogLevels = defaults
+		}
+
+		if l.loggerLevels == nil {
+			l.loggerLevels = make(map[string]uint32)
+		}
+		for name, mask := range rules {
+			l.loggerLevels[name] = mask
+		}
+		return l
+	}
+}
+
 // EnableDebug returns a function to enable the logging of Debug level messages.
 func EnableDebug() func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
@@ -261,21 +614,42 @@ func Transports(s func(list TransactionList) []Transport) func(*configuration) *
 // WithDefault returns a function to sets default parameters that will be included with each entry. Such as ip, processName etc.
 func WithDefault(key string, value interface{}) func(*configuration) *configuration {
 	return func(l *configuration) *configuration {
-		l.defaultData[key] = value
+		if key != "" && value != nil {
+			l.defaultFields = withField(l.defaultFields, Any(key, value))
+		}
 		return l
 	}
 }
 
 func newLogger(c configuration) *logger {
 	l := &logger{
+		levels:        c.LogLevels,
+		levelRules:    make(map[string]uint32),
 		configuration: c,
 	}
 
+	if len(c.rateLimits) > 0 {
+		l.buckets = make(map[uint32]*tokenBucket, len(c.rateLimits))
+		for level, limit := range c.rateLimits {
+			l.buckets[level] = &tokenBucket{rateLimit: limit}
+		}
+	}
+
+	for name, mask := range c.loggerLevels {
+		l.levelRules[name] = mask
+	}
+
 	flag := 0
 	if (c.Mode & outputIncludeLine) != 0 {
 		flag |= log.Lshortfile
 	}
 
+	if ((c.Mode & outputFile) != 0) && (c.DirMode != 0) {
+		if err := os.MkdirAll(c.Path, c.DirMode); err != nil {
+			os.Stderr.Write([]byte("Unable to create the log path: " + err.Error() + "\n"))
+		}
+	}
+
 	if (c.Mode & outputFile) != 0 {
 		validPath := false
 
@@ -314,7 +688,8 @@ func newLogger(c configuration) *logger {
 
 		if (l.configuration.Mode & outputFile) != 0 {
 			outputs = make([]Transport, 1)
-			outputs[0] = newFileTransport(buffer, c.Path, c.Filename, (c.Mode&outputFileRotate) != 0, (c.Mode&outputConsoleInJSONFormat) != 0)
+			l.file = newFileTransport(buffer, &l.configuration)
+			outputs[0] = l.file
 		} else {
 			outputs = make([]Transport, 0)
 		}
@@ -329,24 +704,48 @@ func newLogger(c configuration) *logger {
 		}
 	}
 
-	log.SetFlags(flag)
-	log.SetOutput(l)
+	if (l.file != nil) && (l.configuration.ReopenSignals != nil) {
+		l.watchReopenSignals(l.configuration.ReopenSignals)
+	}
+
+	l.startSampling()
+
+	if (l.configuration.Mode & outputStandardLog) != 0 {
+		log.SetFlags(flag)
+		log.SetOutput(l)
+	}
 
 	return l
 }
 
 func (l *logger) shutdown() {
-	if l.buffer != nil {
-		l.buffer.shutdown()
-	}
+	l.shutdownOnce.Do(func() {
+		if l.reopenStop != nil {
+			close(l.reopenStop)
+		}
+
+		if l.buffer != nil {
+			// the summaries and the repetitions below are written under the timestamp lock held by the blocked writers
+			l.buffer.unblock()
+		}
+
+		l.stopSampling()
+		l.flushRepeated()
+
+		if l.buffer != nil {
+			l.buffer.shutdown()
+		}
+	})
 }
 
 func (l *logger) Write(d []byte) (int, error) {
 	if (l.buffer != nil) || ((l.configuration.Mode & outputConsole) != 0) {
+		l.customTimestampLock.Lock()
+		defer l.customTimestampLock.Unlock()
 		t := time.Now()
-		dumpTimeToBuffer(&l.writeTimestampBuffer, t) // don't have to lock this buf here because Write events are serialized
+		dumpTimeToBuffer(&l.customTimestampBuffer, t)
 		l.write(
-			NewBufferElement(t, l.writeTimestampBuffer, d, 0),
+			NewBufferElement(t, l.customTimestampBuffer, d, 0),
 		)
 	}
 
@@ -365,108 +764,183 @@ func levelToString(level uint32) string {
 		return "warning"
 	case LogLevelError:
 		return "error"
+	case LogLevelFatal:
+		return "fatal"
+	case LogLevelPanic:
+		return "panic"
 	default:
 		return ""
 	}
 }
 
+// write passes the entry through the sampling, redaction, duplicate collapsing and rate limiting stages
+// to the outputs, must be called with the timestamp lock held
 func (l *logger) write(be *BufferElement) {
+	if !l.sample(be) {
+		return
+	}
+
+	l.redact(be)
+
+	if l.collapseRepeated(be) || l.rateLimited(be) {
+		return
+	}
+
+	l.repeat.last = be
+	l.output(be)
+}
+
+// output writes the entry to the console and the buffer
+func (l *logger) output(be *BufferElement) {
 	if (l.configuration.Mode & outputConsole) != 0 {
 		if (l.configuration.Mode & outputConsoleInJSONFormat) != 0 {
-			json, err := be.Marshal()
+			json, err := be.appendJSON(make([]byte, 0, 256), (l.configuration.Mode&outputSortedFields) != 0)
 			if err == nil {
-				l.configuration.ConsoleOutput.Write(json)
-				l.configuration.ConsoleOutput.Write([]byte("\n"))
+				l.configuration.ConsoleOutput.Write(append(json, '\n'))
 			}
 		} else {
 			l.configuration.ConsoleOutput.Write(be.Timestring[:])
-			if ((l.configuration.Mode & outputConsoleOptionalData) != 0) && (be.Data != nil) {
-				l.configuration.ConsoleOutput.Write([]byte(be.serializeData()))
+			if be.Logger != "" {
+				l.configuration.ConsoleOutput.Write([]byte("[" + be.Logger + "] "))
+			}
+			if be.File != "" {
+				l.configuration.ConsoleOutput.Write([]byte(be.caller()))
+			}
+			if (l.configuration.Mode & outputConsoleOptionalData) != 0 {
+				if data := be.appendData(nil, (l.configuration.Mode&outputSortedFields) != 0); len(data) > 0 {
+					l.configuration.ConsoleOutput.Write(data)
+				}
 			}
 			l.configuration.ConsoleOutput.Write([]byte(be.Message))
 			l.configuration.ConsoleOutput.Write([]byte("\n"))
+			if be.Stack != "" {
+				l.configuration.ConsoleOutput.Write([]byte(be.Stack))
+			}
 		}
 	}
 
 	if l.buffer != nil {
+		if l.configuration.Transports != nil {
+			// custom transports may access Data directly
+			be.mergeFields()
+		}
 		l.buffer.write(
 			be,
 		)
 	}
 }
 
-func (l *logger) writeLevel(level uint32, message string) {
+func (l *logger) writeLevel(pcs []uintptr, level uint32, template string, message string) {
 	if (l.buffer != nil) || ((l.configuration.Mode & outputConsole) != 0) {
 		l.customTimestampLock.Lock()
 		defer l.customTimestampLock.Unlock()
 		t := time.Now()
 		dumpTimeToBuffer(&l.customTimestampBuffer, t)
 		be := NewBufferElement(t, l.customTimestampBuffer, []byte(message), level)
+		be.template = template
+		l.setCaller(be, pcs, level)
 		l.write(be)
 	}
 }
 
 // Printf creates creates a new log entry
 func Printf(format string, v ...interface{}) {
-	std.writeLevel(0, fmt.Sprintf(format, v...))
+	std.writeLevel(1, 0, format, fmt.Sprintf(format, v...))
 }
 
 // Println creates creates a new log entry
 func Println(v ...interface{}) {
-	std.writeLevel(0, fmt.Sprintln(v...))
+	std.writeLevel(1, 0, "", fmt.Sprintln(v...))
 }
 
 // Info creates creates a new "info" log entry
 func Info(format string, v ...interface{}) {
-	if (std.configuration.LogLevels & LogLevelInfo) != 0 {
-		std.writeLevel(LogLevelInfo, fmt.Sprintf(format, v...))
+	if std.enabled(LogLevelInfo) {
+		std.writeLevel(1, LogLevelInfo, format, fmt.Sprintf(format, v...))
 	}
 }
 
 // Debug creates creates a new "debug" log entry
 func Debug(format string, v ...interface{}) {
-	if (std.configuration.LogLevels & LogLevelDebug) != 0 {
-		std.writeLevel(LogLevelDebug, fmt.Sprintf(format, v...))
+	if std.enabled(LogLevelDebug) {
+		std.writeLevel(1, LogLevelDebug, format, fmt.Sprintf(format, v...))
 	}
 }
 
 // Trace creates creates a new "trace" log entry
 func Trace(format string, v ...interface{}) {
-	if (std.configuration.LogLevels & LogLevelTrace) != 0 {
-		std.writeLevel(LogLevelTrace, fmt.Sprintf(format, v...))
+	if std.enabled(LogLevelTrace) {
+		std.writeLevel(1, LogLevelTrace, format, fmt.Sprintf(format, v...))
 	}
 }
 
 // Warn creates creates a new "warning" log entry
 func Warn(format string, v ...interface{}) {
-	if (std.configuration.LogLevels & LogLevelWarning) != 0 {
-		std.writeLevel(LogLevelWarning, fmt.Sprintf(format, v...))
+	if std.enabled(LogLevelWarning) {
+		std.writeLevel(1, LogLevelWarning, format, fmt.Sprintf(format, v...))
 	}
 }
 
 // Error creates creates a new "error" log entry
 func Error(format string, v ...interface{}) {
-	if (std.configuration.LogLevels & LogLevelError) != 0 {
-		std.writeLevel(LogLevelError, fmt.Sprintf(format, v...))
+	if std.enabled(LogLevelError) {
+		std.writeLevel(1, LogLevelError, format, fmt.Sprintf(format, v...))
 	}
 }
 
+// Fatal creates a new "fatal" log entry, flushes all the outputs and terminates the process with os.Exit(1)
+func Fatal(format string, v ...interface{}) {
+	std.writeLevel(1, LogLevelFatal, format, fmt.Sprintf(format, v...))
+	std.core.fatal()
+}
+
+// Panic creates a new "panic" log entry, flushes all the outputs and panics with the message.  Transports
+// not implementing Drainer are given up to 5 seconds to deliver the record
+func Panic(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	std.writeLevel(1, LogLevelPanic, format, message)
+	std.core.drain()
+	panic(message)
+}
+
 // With creates a new log entry with optional parameters
 func With(key string, value interface{}) *BufferElement {
-	be := inPlaceBufferElement(std)
-	if key != "" && value != nil {
-		be.Data[key] = value
+	return std.element().With(key, value)
+}
+
+// fatal shuts the logger down delivering all pending records and terminates the process
+func (l *logger) fatal() {
+	l.shutdown()
+
+	exit := l.configuration.ExitFunc
+	if exit == nil {
+		exit = os.Exit
 	}
-	return be
+	exit(1)
 }
 
-func (l *logger) submit(be *BufferElement, message string, level uint32) {
+// drain synchronously delivers all pending records to the outputs
+func (l *logger) drain() {
+	if l.buffer != nil {
+		l.buffer.drain()
+	}
+}
+
+func (l *logger) submit(pcs []uintptr, be *BufferElement, message string, level uint32) {
+	l.submitAt(be, time.Time{}, pcs, message, level)
+}
+
+// submitAt writes the entry with the given timestamp (current time if zero) and the caller program counters
+func (l *logger) submitAt(be *BufferElement, t time.Time, pcs []uintptr, message string, level uint32) {
 	if (l.buffer != nil) || ((l.configuration.Mode & outputConsole) != 0) {
 		l.customTimestampLock.Lock()
 		defer l.customTimestampLock.Unlock()
-		t := time.Now()
+		if t.IsZero() {
+			t = time.Now()
+		}
 		dumpTimeToBuffer(&l.customTimestampBuffer, t)
 		be.fill(t, l.customTimestampBuffer, []byte(message), level)
+		l.setCaller(be, pcs, level)
 		l.write(be)
 	}
 }
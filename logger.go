@@ -0,0 +1,143 @@
+package loge
+
+import (
+	"fmt"
+)
+
+// Logger is an independent logger instance with its own configuration, outputs and default data
+type Logger struct {
+	levelCache uint64 // levels resolved for the named logger combined with the rules generation, accessed atomically
+
+	core   *logger
+	name   string
+	fields []Field // bound fields starting with the default data, never modified after the logger is created
+}
+
+// New creates a new independent logger instance. Unlike Init it does not replace the package level logger
+// and does not redirect the standard log package unless enabled with EnableStandardLog(true).
+// Shutdown must be called to ensure log messages are flushed.
+func New(decorators ...func(*configuration) *configuration) *Logger {
+	c := &configuration{}
+
+	for _, decorator := range decorators {
+		c = decorator(c)
+	}
+
+	return &Logger{core: newLogger(*c)}
+}
+
+// Shutdown flushes all pending log messages and stops the logger outputs
+func (lg *Logger) Shutdown() {
+	lg.core.shutdown()
+}
+
+// Write implements io.Writer so the logger can be used as an output for the standard log.Logger
+func (lg *Logger) Write(d []byte) (int, error) {
+	return lg.core.Write(d)
+}
+
+// Named returns a logger for the named component sharing the outputs with the parent logger.
+// Names of the nested components are separated with dots ("db.pool"), log levels of the component are
+// resolved from the closest configured parent component falling back to the logger levels.
+func (lg *Logger) Named(name string) *Logger {
+	if lg.name != "" {
+		name = lg.name + "." + name
+	}
+
+	return &Logger{core: lg.core, name: name, fields: lg.fields}
+}
+
+// writeLevel writes the message formatted from the template (empty if the message has no template),
+// depth is the number of frames between the caller of writeLevel and the user code
+func (lg *Logger) writeLevel(depth int, level uint32, template string, message string) {
+	pcs := lg.core.capture(depth+1, level)
+
+	if lg.fields != nil {
+		be := lg.element()
+		be.template = template
+		lg.core.submit(pcs, be, message, level)
+		return
+	}
+
+	if lg.name == "" {
+		lg.core.writeLevel(pcs, level, template, message)
+		return
+	}
+
+	lg.core.submit(pcs, &BufferElement{Logger: lg.name, template: template}, message, level)
+}
+
+// submit writes the entry, depth is the number of frames between the caller of submit and the user code
+func (lg *Logger) submit(depth int, be *BufferElement, template string, message string, level uint32) {
+	be.template = template
+	lg.core.submit(lg.core.capture(depth+1, level), be, message, level)
+}
+
+// Printf creates creates a new log entry
+func (lg *Logger) Printf(format string, v ...interface{}) {
+	lg.writeLevel(1, 0, format, fmt.Sprintf(format, v...))
+}
+
+// Println creates creates a new log entry
+func (lg *Logger) Println(v ...interface{}) {
+	lg.writeLevel(1, 0, "", fmt.Sprintln(v...))
+}
+
+// Info creates creates a new "info" log entry
+func (lg *Logger) Info(format string, v ...interface{}) {
+	if lg.enabled(LogLevelInfo) {
+		lg.writeLevel(1, LogLevelInfo, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// Debug creates creates a new "debug" log entry
+func (lg *Logger) Debug(format string, v ...interface{}) {
+	if lg.enabled(LogLevelDebug) {
+		lg.writeLevel(1, LogLevelDebug, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// Trace creates creates a new "trace" log entry
+func (lg *Logger) Trace(format string, v ...interface{}) {
+	if lg.enabled(LogLevelTrace) {
+		lg.writeLevel(1, LogLevelTrace, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// Warn creates creates a new "warning" log entry
+func (lg *Logger) Warn(format string, v ...interface{}) {
+	if lg.enabled(LogLevelWarning) {
+		lg.writeLevel(1, LogLevelWarning, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// Error creates creates a new "error" log entry
+func (lg *Logger) Error(format string, v ...interface{}) {
+	if lg.enabled(LogLevelError) {
+		lg.writeLevel(1, LogLevelError, format, fmt.Sprintf(format, v...))
+	}
+}
+
+// Fatal creates a new "fatal" log entry, flushes all the outputs and terminates the process with os.Exit(1)
+func (lg *Logger) Fatal(format string, v ...interface{}) {
+	lg.writeLevel(1, LogLevelFatal, format, fmt.Sprintf(format, v...))
+	lg.core.fatal()
+}
+
+// Panic creates a new "panic" log entry, flushes all the outputs and panics with the message.  Transports
+// not implementing Drainer are given up to 5 seconds to deliver the record
+func (lg *Logger) Panic(format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	lg.writeLevel(1, LogLevelPanic, format, message)
+	lg.core.drain()
+	panic(message)
+}
+
+// With creates a child logger with the key/value bound to every record it creates.  Bound values are added on
+// top of the default data, child loggers are safe to reuse from multiple goroutines.
+func (lg *Logger) With(key string, value interface{}) *Logger {
+	if key == "" || value == nil {
+		return lg.WithFields()
+	}
+	return lg.WithFields(Any(key, value))
+}
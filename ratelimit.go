@@ -0,0 +1,157 @@
+package loge
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimit is the token bucket policy of a level
+type rateLimit struct {
+	rate  float64 // tokens per second
+	burst float64 // bucket capacity
+}
+
+// tokenBucket limits the records of a level, accessed under the logger timestamp lock
+type tokenBucket struct {
+	rateLimit
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+
+	if now.After(b.last) {
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// repeatState tracks the consecutive identical records, accessed under the logger timestamp lock
+type repeatState struct {
+	last     *BufferElement // last written record
+	count    int            // repetitions of the last record not written yet
+	lastTime time.Time      // time of the last repetition
+	since    time.Time      // time of the first repetition not written yet
+	timer    *time.Timer    // writes the repetitions once the collapse interval elapses
+}
+
+// rateLimited reports if the entry exceeds the rate limit of its level, fatal and panic are never limited
+func (l *logger) rateLimited(be *BufferElement) bool {
+	if (be.Level & (LogLevelFatal | LogLevelPanic)) != 0 {
+		return false
+	}
+
+	bucket, ok := l.buckets[be.Level]
+	if !ok || bucket.allow(be.Timestamp) {
+		return false
+	}
+
+	atomic.AddUint64(&l.rateLimitedCount, 1)
+	return true
+}
+
+// collapseRepeated reports if the entry is a repetition of the last written record and should not be written,
+// the number of repetitions is written once a different record arrives or the collapse interval elapses
+func (l *logger) collapseRepeated(be *BufferElement) bool {
+	if l.configuration.CollapseInterval <= 0 {
+		return false
+	}
+
+	if (be.Level & (LogLevelFatal | LogLevelPanic)) != 0 {
+		// the repetitions are written before the record terminating the process
+		l.writeRepeated()
+		return false
+	}
+
+	r := &l.repeat
+	if (r.last != nil) && sameRecord(r.last, be) {
+		if r.count == 0 {
+			r.since = be.Timestamp
+			l.scheduleRepeated()
+		}
+		r.count++
+		r.lastTime = be.Timestamp
+
+		if be.Timestamp.Sub(r.since) < l.configuration.CollapseInterval {
+			return true
+		}
+
+		l.writeRepeated()
+		return true
+	}
+
+	l.writeRepeated()
+	return false
+}
+
+// writeRepeated writes the number of repetitions of the last record if any
+func (l *logger) writeRepeated() {
+	r := &l.repeat
+	if r.count == 0 {
+		return
+	}
+
+	dumpTimeToBuffer(&l.customTimestampBuffer, r.lastTime)
+	be := &BufferElement{Logger: r.last.Logger}
+	be.fill(r.lastTime, l.customTimestampBuffer, []byte(fmt.Sprintf("last message repeated %d times", r.count)), r.last.Level)
+	r.count = 0
+
+	l.output(be)
+}
+
+// scheduleRepeated arms the timer writing the repetitions if no other record arrives within the collapse interval,
+// must be called with the timestamp lock held
+func (l *logger) scheduleRepeated() {
+	if l.repeat.timer == nil {
+		l.repeat.timer = time.AfterFunc(l.configuration.CollapseInterval, l.expireRepeated)
+		return
+	}
+	l.repeat.timer.Reset(l.configuration.CollapseInterval)
+}
+
+// expireRepeated writes the repetitions pending for the collapse interval
+func (l *logger) expireRepeated() {
+	l.customTimestampLock.Lock()
+	defer l.customTimestampLock.Unlock()
+
+	// the timer might have fired before it was rearmed for the next repetitions
+	if (l.repeat.count > 0) && (time.Since(l.repeat.since) >= l.configuration.CollapseInterval) {
+		l.writeRepeated()
+	}
+}
+
+// flushRepeated writes the number of pending repetitions at shutdown
+func (l *logger) flushRepeated() {
+	l.customTimestampLock.Lock()
+	defer l.customTimestampLock.Unlock()
+
+	if l.repeat.timer != nil {
+		l.repeat.timer.Stop()
+	}
+	l.writeRepeated()
+}
+
+func sameRecord(a, b *BufferElement) bool {
+	return (a.Level == b.Level) &&
+		(a.Message == b.Message) &&
+		(a.Logger == b.Logger) &&
+		(a.File == b.File) &&
+		(a.Line == b.Line) &&
+		reflect.DeepEqual(a.Fields, b.Fields) &&
+		reflect.DeepEqual(a.dataValues(), b.dataValues())
+}
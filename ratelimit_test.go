@@ -0,0 +1,29 @@
+package loge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFatalWritesRepetitions(t *testing.T) {
+	h := &recordingHandler{}
+	lg := New(
+		CollapseRepeated(time.Minute),
+		TransactionTimeout(time.Hour),
+		ExitFunc(func(int) {}),
+		Transports(func(list TransactionList) []Transport {
+			return []Transport{WrapTransport(list, h)}
+		}),
+	)
+
+	for i := 0; i < 3; i++ {
+		lg.Printf("repeated")
+	}
+	lg.Fatal("fatal")
+
+	want := []string{"repeated", "last message repeated 2 times", "fatal"}
+	if !reflect.DeepEqual(h.messages, want) {
+		t.Errorf("delivered %q, want %q", h.messages, want)
+	}
+}
@@ -0,0 +1,197 @@
+package loge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redactable is implemented by the types replacing themselves with a safe representation before being logged
+type Redactable interface {
+	Redact() interface{}
+}
+
+// Redactor replaces the sensitive value
+type Redactor func(value string) string
+
+// RedactRule replaces the matches of the pattern with the redactor output
+type RedactRule struct {
+	Pattern  *regexp.Regexp
+	Redactor Redactor
+}
+
+// Common sensitive data patterns for RedactPattern, e.g. RedactPattern(PatternEmail, Hash(salt))
+var (
+	PatternCardNumber  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	PatternBearerToken = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`)
+	PatternEmail       = regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)
+)
+
+// Mask returns a redactor replacing the value with the replacement, e.g. "[REDACTED]"
+func Mask(replacement string) Redactor {
+	return func(string) string {
+		return replacement
+	}
+}
+
+// MaskKeepLast returns a redactor replacing all but last n characters of the value with '*'
+func MaskKeepLast(n int) Redactor {
+	return func(value string) string {
+		r := []rune(value)
+		for i := 0; i < len(r)-n; i++ {
+			r[i] = '*'
+		}
+		return string(r)
+	}
+}
+
+// Hash returns a redactor replacing the value with the salted SHA-256 hash prefix "sha256:0123456789abcdef",
+// so the records with the same value could still be correlated
+func Hash(salt string) Redactor {
+	return func(value string) string {
+		sum := sha256.Sum256([]byte(salt + value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+}
+
+// redact applies the Redactable values, the redacted keys and the redaction rules to the entry
+func (l *logger) redact(be *BufferElement) {
+	for _, rule := range l.configuration.RedactRules {
+		be.Message = rule.apply(be.Message)
+	}
+
+	copied := false
+	for i := range be.Fields {
+		f, changed := l.redactField(be.Fields[i])
+		if !changed {
+			continue
+		}
+
+		if !copied {
+			// the fields might be shared with the logger
+			fields := make([]Field, len(be.Fields))
+			copy(fields, be.Fields)
+			be.Fields = fields
+			copied = true
+		}
+		be.Fields[i] = f
+	}
+
+	if len(be.Data) > 0 {
+		be.Data, _ = l.redactData(be.Data)
+	}
+}
+
+func (l *logger) redactField(f Field) (Field, bool) {
+	if redactor := l.redactor(f.Key); redactor != nil {
+		return String(f.Key, redactor(string(f.appendText(nil, false)))), true
+	}
+
+	switch f.Type {
+	case FieldString:
+		if s := l.redactString(f.str); s != f.str {
+			return String(f.Key, s), true
+		}
+	case FieldAny:
+		if v, changed := l.redactValue(f.iface); changed {
+			return Any(f.Key, v), true
+		}
+	}
+	return f, false
+}
+
+// redactData returns the data with the values redacted, the data is copied if any value is changed
+func (l *logger) redactData(data map[string]interface{}) (map[string]interface{}, bool) {
+	var res map[string]interface{}
+	for k, v := range data {
+		var changed bool
+		if redactor := l.redactor(k); redactor != nil {
+			v, changed = redactor(fmt.Sprint(v)), true
+		} else {
+			v, changed = l.redactValue(v)
+		}
+
+		if !changed {
+			continue
+		}
+
+		if res == nil {
+			res = make(map[string]interface{}, len(data))
+			for k, v := range data {
+				res[k] = v
+			}
+		}
+		res[k] = v
+	}
+
+	if res == nil {
+		return data, false
+	}
+	return res, true
+}
+
+func (l *logger) redactValue(v interface{}) (interface{}, bool) {
+	switch t := v.(type) {
+	case Redactable:
+		r := t.Redact()
+		if s, ok := r.(string); ok {
+			r = l.redactString(s)
+		}
+		return r, true
+	case string:
+		if s := l.redactString(t); s != t {
+			return s, true
+		}
+	case map[string]interface{}:
+		return l.redactData(t)
+	case fieldGroup:
+		return l.redactGroup(t)
+	}
+	return v, false
+}
+
+// redactGroup returns the group with the nested fields redacted, the group is copied if any field is changed
+func (l *logger) redactGroup(g fieldGroup) (fieldGroup, bool) {
+	var res fieldGroup
+	for i := range g {
+		f, changed := l.redactField(g[i])
+		if !changed {
+			continue
+		}
+
+		if res == nil {
+			res = make(fieldGroup, len(g))
+			copy(res, g)
+		}
+		res[i] = f
+	}
+
+	if res == nil {
+		return g, false
+	}
+	return res, true
+}
+
+func (l *logger) redactString(s string) string {
+	for _, rule := range l.configuration.RedactRules {
+		s = rule.apply(s)
+	}
+	return s
+}
+
+// redactor returns the redactor of the key (case insensitive), nil if the key is not redacted
+func (l *logger) redactor(key string) Redactor {
+	if len(l.configuration.RedactKeys) == 0 {
+		return nil
+	}
+	return l.configuration.RedactKeys[strings.ToLower(key)]
+}
+
+func (r RedactRule) apply(s string) string {
+	if !r.Pattern.MatchString(s) {
+		return s
+	}
+	return r.Pattern.ReplaceAllStringFunc(s, r.Redactor)
+}
@@ -0,0 +1,71 @@
+package loge
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Reopen makes the file output close and recreate the log file at the next flush.
+// Should be called after the log file was moved by an external tool such as logrotate.
+func Reopen() {
+	std.Reopen()
+}
+
+// Reopen makes the file output close and recreate the log file at the next flush.
+func (lg *Logger) Reopen() {
+	lg.core.reopen()
+}
+
+func (l *logger) reopen() {
+	if l.file != nil {
+		l.file.reopen()
+	}
+}
+
+func (l *logger) watchReopenSignals(signals []os.Signal) {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	l.reopenStop = make(chan struct{})
+	signal.Notify(ch, signals...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				l.reopen()
+			case <-l.reopenStop:
+				signal.Stop(ch)
+				return
+			}
+		}
+	}()
+}
+
+func (ft *fileOutputTransport) reopen() {
+	atomic.StoreUint32(&ft.reopenRequested, 1)
+
+	select {
+	case ft.signal <- struct{}{}:
+	default:
+	}
+}
+
+// fileMoved reports whether the open file was deleted or replaced under its name
+func (ft *fileOutputTransport) fileMoved() bool {
+	current, err := ft.file.Stat()
+	if err != nil {
+		return true
+	}
+
+	named, err := os.Stat(ft.currentFilename)
+	if err != nil {
+		return true
+	}
+
+	return !os.SameFile(current, named)
+}
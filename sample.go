@@ -0,0 +1,179 @@
+package loge
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplingPolicy limits the records with the same level and message template, the first records of each interval
+// are written, then every Thereafter-th record.  Zero policy disables the sampling.
+type SamplingPolicy struct {
+	Interval   time.Duration // sampling interval, the counters are reset at the start of each interval
+	First      int           // records written per interval
+	Thereafter int           // every Thereafter-th record written after First (0 suppresses all of them)
+}
+
+func (p SamplingPolicy) enabled() bool {
+	return (p.Interval > 0) && (p.First > 0)
+}
+
+type samplingKey struct {
+	level    uint32
+	logger   string
+	template string
+}
+
+type samplingCounter struct {
+	policy     SamplingPolicy
+	start      time.Time // start of the current interval
+	count      int       // records of the current interval
+	suppressed uint64    // records suppressed since the last summary
+}
+
+type sampler struct {
+	lock     sync.Mutex
+	counters map[samplingKey]*samplingCounter
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startSampling starts the sampler if the sampling is enabled globally or for any named logger
+func (l *logger) startSampling() {
+	interval := time.Duration(0)
+	for _, p := range l.configuration.loggerSampling {
+		if p.enabled() && ((interval == 0) || (p.Interval < interval)) {
+			interval = p.Interval
+		}
+	}
+	if p := l.configuration.Sampling; p.enabled() && ((interval == 0) || (p.Interval < interval)) {
+		interval = p.Interval
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	l.sampler = &sampler{
+		counters: make(map[samplingKey]*samplingCounter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go l.summaryLoop(interval)
+}
+
+// stopSampling stops the sampler writing the last summaries
+func (l *logger) stopSampling() {
+	if l.sampler != nil {
+		close(l.sampler.stop)
+		<-l.sampler.done
+	}
+}
+
+// summaryLoop periodically writes the number of suppressed records of each key
+func (l *logger) summaryLoop(interval time.Duration) {
+	defer close(l.sampler.done)
+
+	tm := time.NewTicker(interval)
+	defer tm.Stop()
+
+	for {
+		select {
+		case <-l.sampler.stop:
+			l.writeSummaries(time.Time{})
+			return
+		case now := <-tm.C:
+			l.writeSummaries(now)
+		}
+	}
+}
+
+// writeSummaries writes the summaries of the suppressed records and removes the counters idle for more than
+// the interval, all the counters are removed if now is zero
+func (l *logger) writeSummaries(now time.Time) {
+	type summary struct {
+		key        samplingKey
+		suppressed uint64
+	}
+
+	var summaries []summary
+
+	l.sampler.lock.Lock()
+	for key, c := range l.sampler.counters {
+		if c.suppressed > 0 {
+			summaries = append(summaries, summary{key: key, suppressed: c.suppressed})
+			c.suppressed = 0
+		}
+
+		if now.IsZero() || (now.Sub(c.start) > 2*c.policy.Interval) {
+			delete(l.sampler.counters, key)
+		}
+	}
+	l.sampler.lock.Unlock()
+
+	for _, s := range summaries {
+		be := &BufferElement{
+			Logger: s.key.logger,
+			Fields: []Field{String("sampled", s.key.template), Int64("suppressed", int64(s.suppressed))},
+		}
+		l.submit(nil, be, fmt.Sprintf("%d similar records suppressed by sampling", s.suppressed), s.key.level)
+	}
+}
+
+// sample reports if the entry passes the sampling, the entries without the template, fatal and panic are never sampled
+func (l *logger) sample(be *BufferElement) bool {
+	if (l.sampler == nil) || (be.template == "") || ((be.Level & (LogLevelFatal | LogLevelPanic)) != 0) {
+		return true
+	}
+
+	key := samplingKey{level: be.Level, logger: be.Logger, template: be.template}
+
+	l.sampler.lock.Lock()
+	defer l.sampler.lock.Unlock()
+
+	c, ok := l.sampler.counters[key]
+	if !ok {
+		c = &samplingCounter{policy: l.samplingPolicy(be.Logger), start: be.Timestamp}
+		l.sampler.counters[key] = c
+	}
+
+	if !c.policy.enabled() {
+		return true
+	}
+
+	if be.Timestamp.Sub(c.start) >= c.policy.Interval {
+		c.start = be.Timestamp
+		c.count = 0
+	}
+
+	c.count++
+	if c.count <= c.policy.First {
+		return true
+	}
+
+	if (c.policy.Thereafter > 0) && ((c.count-c.policy.First)%c.policy.Thereafter == 0) {
+		return true
+	}
+
+	c.suppressed++
+	return false
+}
+
+// samplingPolicy returns the policy of the longest matching named logger prefix or the default policy
+func (l *logger) samplingPolicy(name string) SamplingPolicy {
+	for name != "" {
+		if p, ok := l.configuration.loggerSampling[name]; ok {
+			return p
+		}
+
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+
+	return l.configuration.Sampling
+}
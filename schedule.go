@@ -0,0 +1,181 @@
+package loge
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Predefined rotation intervals
+const (
+	RotateHourly = time.Hour
+	RotateDaily  = 24 * time.Hour
+	RotateWeekly = 7 * 24 * time.Hour
+)
+
+const (
+	defaultFilePattern = "{date:20060102}.log"
+	datePlaceholder    = "{date:"
+)
+
+var (
+	errInvalidFilePattern = errors.New("file pattern must contain a single {date:layout} placeholder")
+	errFilePatternPeriods = errors.New("file pattern must produce different names for the consecutive rotation periods")
+)
+
+// defaultPattern returns the default file pattern of the rotation interval, the date is followed by the time
+// of the day for the intervals shorter than a day
+func defaultPattern(interval time.Duration) string {
+	switch {
+	case (interval <= 0) || (interval%RotateDaily == 0):
+		return defaultFilePattern
+	case interval%time.Hour == 0:
+		return "{date:20060102-15}.log"
+	case interval%time.Minute == 0:
+		return "{date:20060102-1504}.log"
+	case interval%time.Second == 0:
+		return "{date:20060102-150405}.log"
+	}
+	return "{date:20060102-150405.000}.log"
+}
+
+// rotationSchedule computes the rotation periods and file names of the rotated log files.
+// File names are built from the pattern as <prefix><before><date formatted with layout><after>
+type rotationSchedule struct {
+	prefix   string
+	before   string
+	layout   string
+	after    string
+	interval time.Duration
+	location *time.Location
+}
+
+func newRotationSchedule(prefix string, pattern string, interval time.Duration, location *time.Location) (*rotationSchedule, error) {
+	if interval <= 0 {
+		interval = RotateDaily
+	}
+
+	if pattern == "" {
+		pattern = defaultPattern(interval)
+	}
+
+	if location == nil {
+		location = time.Local
+	}
+
+	start := strings.Index(pattern, datePlaceholder)
+	if start < 0 {
+		return nil, errInvalidFilePattern
+	}
+
+	end := strings.Index(pattern[start:], "}")
+	if end < 0 {
+		return nil, errInvalidFilePattern
+	}
+	end += start
+
+	s := &rotationSchedule{
+		prefix:   prefix,
+		before:   pattern[:start],
+		layout:   pattern[start+len(datePlaceholder) : end],
+		after:    pattern[end+1:],
+		interval: interval,
+		location: location,
+	}
+
+	if (s.layout == "") || strings.Contains(s.after, datePlaceholder) || strings.ContainsRune(s.before+s.after, filepath.Separator) {
+		return nil, errInvalidFilePattern
+	}
+
+	if !s.distinct() {
+		return nil, errFilePatternPeriods
+	}
+
+	return s, nil
+}
+
+// distinct reports whether the consecutive rotation periods get different file names, e.g. a daily layout
+// can't be used with the hourly rotation
+func (s *rotationSchedule) distinct() bool {
+	start := s.periodStart(time.Date(2001, 1, 1, 0, 0, 0, 0, s.location))
+	for i := 0; i < 48; i++ {
+		end := s.periodEnd(start)
+		if s.name(start) == s.name(end) {
+			return false
+		}
+		start = end
+	}
+	return true
+}
+
+// periodStart returns the beginning of the rotation period containing t. Intervals shorter than a day
+// are aligned to the midnight, longer intervals are counted in whole days starting on Monday, January 1, 2001.
+func (s *rotationSchedule) periodStart(t time.Time) time.Time {
+	t = t.In(s.location)
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, s.location)
+
+	if s.interval < RotateDaily {
+		return midnight.Add(t.Sub(midnight) / s.interval * s.interval)
+	}
+
+	days := int(s.interval / RotateDaily)
+	reference := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	elapsed := int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Sub(reference) / RotateDaily)
+	offset := ((elapsed % days) + days) % days
+
+	return time.Date(year, month, day-offset, 0, 0, 0, 0, s.location)
+}
+
+// periodEnd returns the beginning of the period following the one started at start
+func (s *rotationSchedule) periodEnd(start time.Time) time.Time {
+	year, month, day := start.Date()
+
+	if s.interval < RotateDaily {
+		end := start.Add(s.interval)
+		midnight := time.Date(year, month, day+1, 0, 0, 0, 0, s.location)
+		if end.After(midnight) {
+			return midnight
+		}
+		return end
+	}
+
+	return time.Date(year, month, day+int(s.interval/RotateDaily), 0, 0, 0, 0, s.location)
+}
+
+func (s *rotationSchedule) name(start time.Time) string {
+	return s.prefix + s.before + start.In(s.location).Format(s.layout) + s.after
+}
+
+// match reports whether the file name belongs to the schedule including the indexed names
+// produced by the size limit (name.N.ext)
+func (s *rotationSchedule) match(name string) bool {
+	head := s.prefix + s.before
+	if !strings.HasPrefix(name, head) {
+		return false
+	}
+	rest := name[len(head):]
+
+	dateLength := len(time.Date(2001, 1, 1, 0, 0, 0, 0, s.location).Format(s.layout))
+	if len(rest) < dateLength {
+		return false
+	}
+
+	if _, err := time.ParseInLocation(s.layout, rest[:dateLength], s.location); err != nil {
+		return false
+	}
+	rest = rest[dateLength:]
+
+	if rest == s.after {
+		return true
+	}
+
+	ext := filepath.Ext(s.after)
+	tail := strings.TrimSuffix(s.after, ext)
+	if !strings.HasPrefix(rest, tail+".") || !strings.HasSuffix(rest, ext) {
+		return false
+	}
+
+	return isNumber(rest[len(tail)+1 : len(rest)-len(ext)])
+}
@@ -0,0 +1,144 @@
+package loge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRotationSchedule(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		interval time.Duration
+		err      error
+		name     string // name of the period started at 2026-10-17 13:00 UTC
+	}{
+		{"", RotateDaily, nil, "app-20261017.log"},
+		{"", RotateHourly, nil, "app-20261017-13.log"},
+		{"", 15 * time.Minute, nil, "app-20261017-1300.log"},
+		{"", time.Second, nil, "app-20261017-130000.log"},
+		{"{date:2006-01-02}-web.log", RotateWeekly, nil, "app-2026-10-17-web.log"},
+		{"{date:20060102}.log", RotateHourly, errFilePatternPeriods, ""},
+		{"{date:200601}.log", RotateDaily, errFilePatternPeriods, ""},
+		{"{date:15}.log", RotateDaily, errFilePatternPeriods, ""},
+		{"app.log", RotateDaily, errInvalidFilePattern, ""},
+		{"{date:}.log", RotateDaily, errInvalidFilePattern, ""},
+		{"{date:20060102.log", RotateDaily, errInvalidFilePattern, ""},
+		{"{date:20060102}/app.log", RotateDaily, errInvalidFilePattern, ""},
+	}
+
+	for _, test := range tests {
+		s, err := newRotationSchedule("app-", test.pattern, test.interval, time.UTC)
+		if err != test.err {
+			t.Errorf("newRotationSchedule(%q, %v) error = %v, want %v", test.pattern, test.interval, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if name := s.name(time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC)); name != test.name {
+			t.Errorf("newRotationSchedule(%q, %v) name = %q, want %q", test.pattern, test.interval, name, test.name)
+		}
+	}
+}
+
+func TestRotationPeriods(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	edt := time.FixedZone("EDT", -4*3600)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		location *time.Location
+		t        time.Time
+		start    time.Time
+		end      time.Time
+	}{
+		{"second", time.Second, time.UTC,
+			time.Date(2026, 10, 17, 13, 45, 10, 500, time.UTC),
+			time.Date(2026, 10, 17, 13, 45, 10, 0, time.UTC),
+			time.Date(2026, 10, 17, 13, 45, 11, 0, time.UTC)},
+		{"hourly", RotateHourly, time.UTC,
+			time.Date(2026, 10, 17, 13, 45, 0, 0, time.UTC),
+			time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 17, 14, 0, 0, 0, time.UTC)},
+		{"7 hours aligned to the midnight", 7 * time.Hour, time.UTC,
+			time.Date(2026, 10, 17, 22, 30, 0, 0, time.UTC),
+			time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"daily", RotateDaily, time.UTC,
+			time.Date(2026, 10, 17, 13, 45, 0, 0, time.UTC),
+			time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"daily in the location", RotateDaily, newYork,
+			time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 16, 0, 0, 0, 0, newYork),
+			time.Date(2026, 10, 17, 0, 0, 0, 0, newYork)},
+		{"weekly starts on Monday", RotateWeekly, time.UTC,
+			time.Date(2026, 10, 17, 13, 45, 0, 0, time.UTC),
+			time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"hourly before the end of daylight saving time", RotateHourly, newYork,
+			time.Date(2026, 11, 1, 1, 30, 0, 0, edt),
+			time.Date(2026, 11, 1, 1, 0, 0, 0, edt),
+			time.Date(2026, 11, 1, 1, 0, 0, 0, est)},
+		{"hourly in the repeated hour", RotateHourly, newYork,
+			time.Date(2026, 11, 1, 1, 30, 0, 0, est),
+			time.Date(2026, 11, 1, 1, 0, 0, 0, est),
+			time.Date(2026, 11, 1, 2, 0, 0, 0, est)},
+		{"daily at the end of daylight saving time", RotateDaily, newYork,
+			time.Date(2026, 11, 1, 12, 0, 0, 0, est),
+			time.Date(2026, 11, 1, 0, 0, 0, 0, newYork),
+			time.Date(2026, 11, 2, 0, 0, 0, 0, newYork)},
+	}
+
+	for _, test := range tests {
+		s, err := newRotationSchedule("", "", test.interval, test.location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := s.periodStart(test.t)
+		if !start.Equal(test.start) {
+			t.Errorf("%s: periodStart() = %v, want %v", test.name, start, test.start)
+		}
+		if end := s.periodEnd(start); !end.Equal(test.end) {
+			t.Errorf("%s: periodEnd() = %v, want %v", test.name, end, test.end)
+		}
+	}
+}
+
+func TestRotationScheduleMatch(t *testing.T) {
+	s, err := newRotationSchedule("app-", "{date:20060102}.log", RotateDaily, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"app-20261017.log", true},
+		{"app-20261017.3.log", true},
+		{"app-20261017.12.log", true},
+		{"app-20261017.log.gz", false},
+		{"app-20261017.x.log", false},
+		{"app-20261017..log", false},
+		{"app-20261017.log.1", false},
+		{"app-2026101.log", false},
+		{"app-20261317.log", false},
+		{"web-20261017.log", false},
+		{"app.log", false},
+	}
+
+	for _, test := range tests {
+		if got := s.match(test.name); got != test.want {
+			t.Errorf("match(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+//go:build go1.21
+// +build go1.21
+
+package loge
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a log/slog Handler writing the records through the logger outputs
+type SlogHandler struct {
+	lg     *Logger
+	fields []Field  // attributes added with WithAttrs nested into their groups
+	groups []string // groups opened with WithGroup
+}
+
+// NewSlogHandler creates a log/slog Handler writing the records through the default logger, should be called after Init
+func NewSlogHandler() *SlogHandler {
+	return std.SlogHandler()
+}
+
+// SlogHandler creates a log/slog Handler writing the records through the logger
+func (lg *Logger) SlogHandler() *SlogHandler {
+	return &SlogHandler{lg: lg}
+}
+
+// slogLevel converts slog level into the logger level, levels below slog.LevelDebug are logged as trace
+func slogLevel(level slog.Level) uint32 {
+	switch {
+	case level >= slog.LevelError:
+		return LogLevelError
+	case level >= slog.LevelWarn:
+		return LogLevelWarning
+	case level >= slog.LevelInfo:
+		return LogLevelInfo
+	case level >= slog.LevelDebug:
+		return LogLevelDebug
+	default:
+		return LogLevelTrace
+	}
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.lg.enabled(slogLevel(level))
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	be := h.lg.WithContext(ctx).element()
+	for _, f := range h.fields {
+		be.Fields = withField(be.Fields, f)
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		be.Fields = addAttr(be.Fields, h.groups, a)
+		return true
+	})
+
+	level := slogLevel(r.Level)
+	pcs := h.lg.core.captureAt(r.PC, level)
+
+	be.template = r.Message
+	h.lg.core.submitAt(be, r.Time, pcs, r.Message, level)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := h.fields
+	for _, a := range attrs {
+		fields = addAttr(fields, h.groups, a)
+	}
+
+	return &SlogHandler{lg: h.lg, fields: fields, groups: h.groups}
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+
+	return &SlogHandler{lg: h.lg, fields: h.fields, groups: append(groups, name)}
+}
+
+// addAttr adds the attribute as a field nested into the groups, the groups without attributes are never created
+// as required by slog.Handler
+func addAttr(fields []Field, groups []string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+
+		for _, ga := range attrs {
+			fields = addAttr(fields, groups, ga)
+		}
+		return fields
+	}
+
+	if a.Key == "" {
+		return fields
+	}
+
+	return withGroupField(fields, groups, Any(a.Key, a.Value.Any()))
+}
@@ -0,0 +1,102 @@
+//go:build go1.21
+// +build go1.21
+
+package loge
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogCallerAndStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		stack uint32
+	}{
+		{"caller", 0},
+		{"stack", LogLevelError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &recordingElements{}
+			lg := New(
+				LogLevels(LogLevelError),
+				EnableOutputIncludeLine(true),
+				EnableStackTrace(test.stack),
+				TransactionTimeout(time.Hour),
+				Transports(func(list TransactionList) []Transport {
+					return []Transport{WrapTransport(list, h)}
+				}),
+			)
+
+			slog.New(lg.SlogHandler()).Error("failed")
+			lg.Shutdown()
+
+			if len(h.items) != 1 {
+				t.Fatalf("%d records delivered, want 1", len(h.items))
+			}
+
+			be := h.items[0]
+			if !strings.Contains(be.Function, "TestSlogCallerAndStack") || !strings.HasSuffix(be.File, "slog_test.go") {
+				t.Errorf("caller = %s %s:%d, want the slog call site", be.Function, be.File, be.Line)
+			}
+
+			if test.stack == 0 {
+				if be.Stack != "" {
+					t.Errorf("stack = %q, want none", be.Stack)
+				}
+				return
+			}
+
+			if !strings.HasPrefix(be.Stack, be.Function) || !strings.Contains(be.Stack, "testing.tRunner") {
+				t.Errorf("stack = %q, want it to start at the slog call site", be.Stack)
+			}
+			if strings.Contains(be.Stack, "log/slog") || strings.Contains(be.Stack, "loge.(*SlogHandler)") {
+				t.Errorf("stack = %q, want no slog and handler frames", be.Stack)
+			}
+		})
+	}
+}
+
+type recordingElements struct {
+	items []*BufferElement
+}
+
+func (h *recordingElements) WriteOutTransaction(tr *Transaction) {
+	h.items = append(h.items, tr.Items...)
+}
+
+func (h *recordingElements) FlushTransactions() {}
+
+func TestSlogAttributesOrder(t *testing.T) {
+	tests := []struct {
+		sorted bool
+		want   string
+	}{
+		{false, `"data":{"uid":42,"b":1,"req":{"path":"/","method":"GET","user":{"name":"bob","id":7}}}`},
+		{true, `"data":{"b":1,"req":{"method":"GET","path":"/","user":{"id":7,"name":"bob"}},"uid":42}`},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		lg := New(
+			LogLevels(LogLevelInfo),
+			EnableOutputConsole(true),
+			EnableOutputConsoleInJSONFormat(true),
+			EnableSortedFields(test.sorted),
+			ConsoleOutput(&out),
+		)
+
+		logger := slog.New(lg.SlogHandler()).With("uid", 42, "b", 1).WithGroup("req").With("path", "/")
+		logger.Info("request", "method", "GET", slog.Group("user", "name", "bob", "id", 7), slog.Group("empty"))
+		lg.Shutdown()
+
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("sorted %v: output = %s, want %s", test.sorted, out.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package loge
+
+import (
+	"sync/atomic"
+)
+
+// Statistics contains the logger delivery counters
+type Statistics struct {
+	FileDropped    uint64 // records dropped because the file output was unavailable
+	RateLimited    uint64 // records dropped by the rate limits
+	BufferDropped  uint64 // records dropped by the backpressure policy
+	BacklogExpired uint64 // records expired from the backlog before all the transports delivered them
+	BufferBlocked  uint64 // writes blocked by the backpressure policy
+}
+
+// Stats returns the delivery counters of the default logger
+func Stats() Statistics {
+	return std.Stats()
+}
+
+// Stats returns the delivery counters of the logger
+func (lg *Logger) Stats() Statistics {
+	return lg.core.stats()
+}
+
+func (l *logger) stats() Statistics {
+	s := Statistics{
+		RateLimited: atomic.LoadUint64(&l.rateLimitedCount),
+	}
+
+	if l.buffer != nil {
+		s.BufferDropped = atomic.LoadUint64(&l.buffer.dropped)
+		s.BacklogExpired = atomic.LoadUint64(&l.buffer.expired)
+		s.BufferBlocked = atomic.LoadUint64(&l.buffer.blocked)
+	}
+
+	if l.file != nil {
+		s.FileDropped = atomic.LoadUint64(&l.file.dropped)
+	}
+
+	return s
+}
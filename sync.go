@@ -0,0 +1,72 @@
+package loge
+
+import (
+	"os"
+	"time"
+)
+
+// SyncPolicy defines when the file output commits the written data to the stable storage.
+// Data is always committed when the logger is shut down.
+type SyncPolicy struct {
+	Transactions int           // commit after every N written transactions (0 disables)
+	Interval     time.Duration // commit the written data within the interval (0 disables)
+}
+
+// Predefined sync policies
+var (
+	SyncNever            = SyncPolicy{}
+	SyncEveryTransaction = SyncPolicy{Transactions: 1}
+)
+
+// SyncEvery returns a policy committing the data after every n written transactions
+func SyncEvery(n int) SyncPolicy {
+	return SyncPolicy{Transactions: n}
+}
+
+// SyncInterval returns a policy committing the written data within the interval
+func SyncInterval(d time.Duration) SyncPolicy {
+	return SyncPolicy{Interval: d}
+}
+
+func (p SyncPolicy) enabled() bool {
+	return (p.Transactions > 0) || (p.Interval > 0)
+}
+
+// written updates the sync state after the transaction has been written out
+func (ft *fileOutputTransport) written() {
+	ft.unsynced++
+
+	if (ft.syncPolicy.Transactions > 0) && (ft.unsynced >= ft.syncPolicy.Transactions) {
+		ft.sync()
+		return
+	}
+
+	if (ft.syncPolicy.Interval > 0) && (ft.syncTimer == nil) {
+		ft.syncTimer = time.NewTimer(ft.syncPolicy.Interval)
+	}
+}
+
+func (ft *fileOutputTransport) sync() {
+	ft.unsynced = 0
+
+	if ft.syncTimer != nil {
+		ft.syncTimer.Stop()
+		ft.syncTimer = nil
+	}
+
+	if ft.file == nil {
+		return
+	}
+
+	if err := ft.file.Sync(); err != nil {
+		ft.reportError(err)
+	}
+}
+
+func (ft *fileOutputTransport) reportError(err error) {
+	if ft.errorHandler != nil {
+		ft.errorHandler(err)
+	} else {
+		os.Stderr.Write([]byte("Log file output error: " + err.Error() + "\n"))
+	}
+}
@@ -1,19 +1,11 @@
 package loge
 
 import (
-	"fmt"
-	"path/filepath"
 	"time"
 )
 
 const dateTimeStringLength = 27
 
-func getLogName(path string) string {
-	t := time.Now()
-	ret := fmt.Sprintf("%d%02d%02d.log", t.Year(), t.Month(), t.Day())
-	return filepath.Join(path, ret)
-}
-
 func itoa(buf *[]byte, i int, wid int) {
 	var b [20]byte
 	bp := len(b) - 1
@@ -13,33 +13,38 @@ type TransactionHandler interface {
 
 // WrappedTransport wraps the TransactionHandler
 type WrappedTransport struct {
-	buffer      TransactionList
-	signal      chan struct{}
-	done        chan struct{}
-	trans       []uint64
-	transLocker sync.Mutex
-	wg          sync.WaitGroup
-	terminated  bool
+	buffer       TransactionList
+	signal       chan struct{}
+	drainRequest chan chan struct{}
+	done         chan struct{}
+	trans        []uint64
+	transLocker  sync.Mutex
+	wg           sync.WaitGroup
+	terminated   bool
 
 	handler TransactionHandler
+	filters []Filter
 }
 
-// WrapTransport creates a wrapped transaction handler
-func WrapTransport(buffer TransactionList, handler TransactionHandler) *WrappedTransport {
+// WrapTransport creates a wrapped transaction handler, the handler receives only the records accepted by all the filters
+func WrapTransport(buffer TransactionList, handler TransactionHandler, filters ...Filter) *WrappedTransport {
 	ft := &WrappedTransport{
 		buffer:  buffer,
 		handler: handler,
+		filters: filters,
 		done:    make(chan struct{}),
 		signal:  make(chan struct{}, 1),
 		trans:   make([]uint64, 0),
+
+		drainRequest: make(chan chan struct{}),
 	}
 
+	ft.wg.Add(1)
 	go ft.loop()
 	return ft
 }
 
 func (ft *WrappedTransport) loop() {
-	ft.wg.Add(1)
 	defer ft.wg.Done()
 
 	for {
@@ -47,6 +52,9 @@ func (ft *WrappedTransport) loop() {
 		case <-ft.done:
 			ft.flushAll()
 			return
+		case done := <-ft.drainRequest:
+			ft.flushAll()
+			close(done)
 		case <-ft.signal:
 			ft.flushAll()
 		}
@@ -65,6 +73,16 @@ func (ft *WrappedTransport) NewTransaction(id uint64) {
 	}
 }
 
+// Drain /Drainer handler
+func (ft *WrappedTransport) Drain() {
+	done := make(chan struct{})
+	select {
+	case ft.drainRequest <- done:
+		<-done
+	case <-ft.done:
+	}
+}
+
 // Stop /Transport handler
 func (ft *WrappedTransport) Stop() {
 	close(ft.done)
@@ -89,7 +107,9 @@ func (ft *WrappedTransport) flushAll() {
 	for _, id := range ids {
 		tr, ok := ft.buffer.Get(id, true)
 		if ok {
-			ft.handler.WriteOutTransaction(tr)
+			if tr = filterTransaction(tr, ft.filters); len(tr.Items) > 0 {
+				ft.handler.WriteOutTransaction(tr)
+			}
 		}
 	}
 